@@ -0,0 +1,123 @@
+// Package lint checks parsed queries for common performance and correctness
+// problems.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/sqlparser"
+)
+
+// Severity is how serious a finding is.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Finding is a single problem a rule found in a query.
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Message  string
+}
+
+// Rule checks a single node of a query's AST.  Check is called for every
+// node in the tree and returns a message for each problem it finds there.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+	Check       func(node sqlparser.SQLNode) []string
+}
+
+// Linter runs a set of rules over queries.
+type Linter struct {
+	// Rules are the rules to run.  If nil, every rule in DefaultRules runs.
+	Rules []*Rule
+}
+
+// Lint runs the linter's rules over stmt, returning their findings in the
+// order the offending nodes appear in the tree.
+func (l *Linter) Lint(stmt sqlparser.Statement) []Finding {
+	rules := l.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+
+	findings := make([]Finding, 0)
+	normalizer.Walk(stmt, func(node sqlparser.SQLNode) bool {
+		for _, rule := range rules {
+			for _, msg := range rule.Check(node) {
+				findings = append(findings, Finding{RuleID: rule.ID, Severity: rule.Severity, Message: msg})
+			}
+		}
+		return true
+	})
+	return findings
+}
+
+// LintQuery parses q and runs the linter's rules over it.  An error is
+// returned if q can't be parsed.
+func (l *Linter) LintQuery(q string) (findings []Finding, err error) {
+	// sqlparser panics on some malformed queries.
+	defer func() {
+		if r := recover(); r != nil {
+			findings, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	// sqlparser only recognizes lowercase keywords.
+	stmt, err := sqlparser.Parse(strings.ToLower(q))
+	if err != nil {
+		return nil, err
+	}
+	return l.Lint(stmt), nil
+}
+
+// RulesByID returns the rules in DefaultRules with the given IDs.
+func RulesByID(ids ...string) []*Rule {
+	rules := make([]*Rule, 0, len(ids))
+	for _, rule := range DefaultRules {
+		for _, id := range ids {
+			if rule.ID == id {
+				rules = append(rules, rule)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+// RulesWithout returns the rules in DefaultRules other than those with the
+// given IDs.
+func RulesWithout(ids ...string) []*Rule {
+	rules := make([]*Rule, 0, len(DefaultRules))
+outer:
+	for _, rule := range DefaultRules {
+		for _, id := range ids {
+			if rule.ID == id {
+				continue outer
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -0,0 +1,115 @@
+package lint_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/lint"
+)
+
+var lintTests = []struct {
+	ID               string
+	Input            string
+	ExpectedFindings []string
+}{
+	{"clean query",
+		"SELECT id, name FROM users WHERE id = 5",
+		[]string{},
+	},
+	{"select star",
+		"SELECT * FROM users WHERE id = 5",
+		[]string{"select-star"},
+	},
+	{"count star isn't select star",
+		"SELECT COUNT(*) FROM users WHERE id = 5",
+		[]string{},
+	},
+	{"update without where",
+		"UPDATE users SET name = 'x'",
+		[]string{"update-without-where"},
+	},
+	{"delete without where",
+		"DELETE FROM users",
+		[]string{"delete-without-where"},
+	},
+	{"leading wildcard like",
+		"SELECT id FROM users WHERE name LIKE '%smith' AND email LIKE 'bob%'",
+		[]string{"leading-wildcard-like"},
+	},
+	{"function on column",
+		"SELECT id FROM users WHERE LOWER(name) = 'bob' AND created > NOW()",
+		[]string{"function-on-column"},
+	},
+	{"order by rand",
+		"SELECT id FROM users WHERE id > 5 ORDER BY RAND() LIMIT 1",
+		[]string{"order-by-rand"},
+	},
+	{"large offset",
+		"SELECT id FROM users WHERE id > 5 LIMIT 20 OFFSET 500000",
+		[]string{"large-offset"},
+	},
+	{"not in subquery",
+		"SELECT id FROM users WHERE id NOT IN (SELECT user_id FROM bans)",
+		[]string{"not-in-subquery"},
+	},
+	{"comma join with a join condition",
+		"SELECT users.id FROM users, orders WHERE users.id = orders.user_id",
+		[]string{},
+	},
+	{"implicit cross join",
+		"SELECT users.id FROM users, orders WHERE users.id = 5",
+		[]string{"implicit-cross-join"},
+	},
+	{"implicit cross join of a third table",
+		"SELECT u.id FROM users u, orders o, `items` WHERE o.user_id = u.id AND items.price > 5",
+		[]string{"implicit-cross-join"},
+	},
+	{"comma join of a join",
+		"SELECT u.id FROM users u JOIN orders o ON o.user_id = u.id, items i WHERE i.order_id = o.id",
+		[]string{},
+	},
+}
+
+func TestLint(t *testing.T) {
+	l := &lint.Linter{}
+
+	for _, test := range lintTests {
+		findings, err := l.LintQuery(test.Input)
+		if err != nil {
+			t.Error("test '" + test.ID + "' failed to parse: " + err.Error())
+			continue
+		}
+		ids := make([]string, 0, len(findings))
+		for _, f := range findings {
+			ids = append(ids, f.RuleID)
+		}
+		if fmt.Sprint(test.ExpectedFindings) != fmt.Sprint(ids) {
+			t.Error("test '" + test.ID + "' failed.  actual = " + fmt.Sprint(findings))
+		}
+	}
+}
+
+func TestLintUnparseable(t *testing.T) {
+	l := &lint.Linter{}
+
+	// sqlparser panics on an unterminated comment.
+	if findings, err := l.LintQuery("/*"); err == nil {
+		t.Error("unparseable query was linted.  actual = " + fmt.Sprint(findings))
+	}
+}
+
+func TestLintRuleSelection(t *testing.T) {
+	q := "SELECT * FROM users ORDER BY RAND()"
+
+	l := &lint.Linter{Rules: lint.RulesByID("order-by-rand")}
+	findings, _ := l.LintQuery(q)
+	if len(findings) != 1 || findings[0].RuleID != "order-by-rand" || findings[0].Severity != lint.Warning {
+		t.Error("RulesByID failed.  actual = " + fmt.Sprint(findings))
+	}
+
+	l = &lint.Linter{Rules: lint.RulesWithout("order-by-rand")}
+	findings, _ = l.LintQuery(q)
+	if len(findings) != 1 || findings[0].RuleID != "select-star" {
+		t.Error("RulesWithout failed.  actual = " + fmt.Sprint(findings))
+	}
+}
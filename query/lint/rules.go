@@ -0,0 +1,264 @@
+package lint
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/sqlparser"
+)
+
+// LargeOffset is the OFFSET above which the large-offset rule reports a
+// finding.
+var LargeOffset int64 = 10000
+
+// DefaultRules are the built-in rules.
+var DefaultRules = []*Rule{
+	{
+		ID:          "select-star",
+		Severity:    Info,
+		Description: "SELECT * fetches every column, including ones added later.",
+		Check:       checkSelectStar,
+	},
+	{
+		ID:          "update-without-where",
+		Severity:    Error,
+		Description: "UPDATE without a WHERE clause modifies every row.",
+		Check:       checkUpdateWithoutWhere,
+	},
+	{
+		ID:          "delete-without-where",
+		Severity:    Error,
+		Description: "DELETE without a WHERE clause removes every row.",
+		Check:       checkDeleteWithoutWhere,
+	},
+	{
+		ID:          "leading-wildcard-like",
+		Severity:    Warning,
+		Description: "LIKE patterns starting with a wildcard can't use an index.",
+		Check:       checkLeadingWildcardLike,
+	},
+	{
+		ID:          "function-on-column",
+		Severity:    Warning,
+		Description: "Functions wrapped around columns in WHERE prevent index use.",
+		Check:       checkFunctionOnColumn,
+	},
+	{
+		ID:          "order-by-rand",
+		Severity:    Warning,
+		Description: "ORDER BY RAND() sorts the entire result set.",
+		Check:       checkOrderByRand,
+	},
+	{
+		ID:          "large-offset",
+		Severity:    Warning,
+		Description: "Large OFFSETs read and discard every skipped row.",
+		Check:       checkLargeOffset,
+	},
+	{
+		ID:          "not-in-subquery",
+		Severity:    Warning,
+		Description: "NOT IN with a subquery is slow and returns no rows if the subquery yields a NULL.",
+		Check:       checkNotInSubquery,
+	},
+	{
+		ID:          "implicit-cross-join",
+		Severity:    Warning,
+		Description: "Comma separated tables are joined without an explicit join condition.",
+		Check:       checkImplicitCrossJoin,
+	},
+}
+
+func checkSelectStar(node sqlparser.SQLNode) []string {
+	sel, ok := node.(*sqlparser.Select)
+	if !ok {
+		return nil
+	}
+	for _, se := range sel.SelectExprs {
+		if _, ok := se.(*sqlparser.StarExpr); ok {
+			return []string{"select " + sqlparser.String(se)}
+		}
+	}
+	return nil
+}
+
+func checkUpdateWithoutWhere(node sqlparser.SQLNode) []string {
+	if update, ok := node.(*sqlparser.Update); ok && update.Where == nil {
+		return []string{"update of " + sqlparser.String(update.Table) + " has no where clause"}
+	}
+	return nil
+}
+
+func checkDeleteWithoutWhere(node sqlparser.SQLNode) []string {
+	if del, ok := node.(*sqlparser.Delete); ok && del.Where == nil {
+		return []string{"delete from " + sqlparser.String(del.Table) + " has no where clause"}
+	}
+	return nil
+}
+
+func checkLeadingWildcardLike(node sqlparser.SQLNode) []string {
+	cmp, ok := node.(*sqlparser.ComparisonExpr)
+	if !ok || (cmp.Operator != sqlparser.AST_LIKE && cmp.Operator != sqlparser.AST_NOT_LIKE) {
+		return nil
+	}
+	if pattern, ok := cmp.Right.(sqlparser.StrVal); ok && len(pattern) > 0 && (pattern[0] == '%' || pattern[0] == '_') {
+		return []string{sqlparser.String(cmp.Left) + " " + cmp.Operator + " '" + string(pattern) + "'"}
+	}
+	return nil
+}
+
+func checkFunctionOnColumn(node sqlparser.SQLNode) []string {
+	where, ok := node.(*sqlparser.Where)
+	if !ok || where.Type != sqlparser.AST_WHERE {
+		return nil
+	}
+
+	var msgs []string
+	normalizer.Walk(where.Expr, func(node sqlparser.SQLNode) bool {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			// subqueries have their own WHERE clauses.
+			return false
+		case *sqlparser.ComparisonExpr:
+			for _, side := range []sqlparser.ValExpr{node.Left, node.Right} {
+				if fn, ok := side.(*sqlparser.FuncExpr); ok && hasColumn(fn) {
+					msgs = append(msgs, sqlparser.String(fn))
+				}
+			}
+		case *sqlparser.RangeCond:
+			if fn, ok := node.Left.(*sqlparser.FuncExpr); ok && hasColumn(fn) {
+				msgs = append(msgs, sqlparser.String(fn))
+			}
+		}
+		return true
+	})
+	return msgs
+}
+
+func checkOrderByRand(node sqlparser.SQLNode) []string {
+	order, ok := node.(*sqlparser.Order)
+	if !ok {
+		return nil
+	}
+	if fn, ok := order.Expr.(*sqlparser.FuncExpr); ok && strings.ToLower(string(fn.Name)) == "rand" {
+		return []string{"order by " + sqlparser.String(fn)}
+	}
+	return nil
+}
+
+func checkLargeOffset(node sqlparser.SQLNode) []string {
+	limit, ok := node.(*sqlparser.Limit)
+	if !ok {
+		return nil
+	}
+	num, ok := limit.Offset.(sqlparser.NumVal)
+	if !ok {
+		return nil
+	}
+	if offset, err := strconv.ParseInt(string(num), 0, 64); err == nil && offset > LargeOffset {
+		return []string{"offset " + string(num)}
+	}
+	return nil
+}
+
+func checkNotInSubquery(node sqlparser.SQLNode) []string {
+	cmp, ok := node.(*sqlparser.ComparisonExpr)
+	if !ok || cmp.Operator != sqlparser.AST_NOT_IN {
+		return nil
+	}
+	if _, ok := cmp.Right.(*sqlparser.Subquery); ok {
+		return []string{sqlparser.String(cmp.Left) + " not in (subquery)"}
+	}
+	return nil
+}
+
+func checkImplicitCrossJoin(node sqlparser.SQLNode) []string {
+	sel, ok := node.(*sqlparser.Select)
+	if !ok || len(sel.From) < 2 {
+		return nil
+	}
+
+	// the comma separated tables, by the names their columns are
+	// qualified with, are linked into groups by the WHERE comparisons
+	// between their columns.
+	group := make([]int, len(sel.From))
+	tables := make(map[string]int)
+	for i, expr := range sel.From {
+		group[i] = i
+		for _, name := range tableNames(expr, nil) {
+			tables[name] = i
+		}
+	}
+	find := func(i int) int {
+		for group[i] != i {
+			i = group[i]
+		}
+		return i
+	}
+
+	if sel.Where != nil {
+		normalizer.Walk(sel.Where.Expr, func(node sqlparser.SQLNode) bool {
+			switch node := node.(type) {
+			case *sqlparser.Subquery:
+				return false
+			case *sqlparser.ComparisonExpr:
+				left, lok := columnTable(node.Left, tables)
+				right, rok := columnTable(node.Right, tables)
+				if lok && rok {
+					group[find(left)] = find(right)
+				}
+			}
+			return true
+		})
+	}
+
+	for i := range sel.From {
+		if find(i) != find(0) {
+			return []string{"from " + sqlparser.String(sel.From)}
+		}
+	}
+	return nil
+}
+
+// tableNames appends the names columns can be qualified with to refer to
+// the tables in node.
+func tableNames(node sqlparser.TableExpr, names []string) []string {
+	switch node := node.(type) {
+	case *sqlparser.AliasedTableExpr:
+		if len(node.As) > 0 {
+			return append(names, strings.Trim(string(node.As), "`"))
+		}
+		if table, ok := node.Expr.(*sqlparser.TableName); ok {
+			return append(names, strings.Trim(string(table.Name), "`"))
+		}
+	case *sqlparser.ParenTableExpr:
+		return tableNames(node.Expr, names)
+	case *sqlparser.JoinTableExpr:
+		return tableNames(node.RightExpr, tableNames(node.LeftExpr, names))
+	}
+	return names
+}
+
+// columnTable returns the index in tables of the table a qualified column
+// belongs to.
+func columnTable(node sqlparser.ValExpr, tables map[string]int) (int, bool) {
+	col, ok := node.(*sqlparser.ColName)
+	if !ok || len(col.Qualifier) == 0 {
+		return 0, false
+	}
+	i, ok := tables[strings.Trim(string(col.Qualifier), "`")]
+	return i, ok
+}
+
+// hasColumn returns true if a column is referenced anywhere in node.
+func hasColumn(node sqlparser.SQLNode) bool {
+	found := false
+	normalizer.Walk(node, func(node sqlparser.SQLNode) bool {
+		if _, ok := node.(*sqlparser.ColName); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,237 @@
+package normalizer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// aliasScope holds the aliases a SELECT defines, mapped to the positional
+// names they're replaced with.
+type aliasScope struct {
+	// table aliases, which are case sensitive.
+	tables map[string]string
+	// column aliases, lowercased since they aren't case sensitive.
+	columns map[string]string
+	// the column aliases of the scope's derived tables, by the derived
+	// table's new name.
+	derived map[string]map[string]string
+	// derivedOrder is the new names of the derived tables, in order.
+	derivedOrder []string
+	// whether column aliases can be referred to, as they can be in GROUP
+	// BY, HAVING and ORDER BY.
+	columnRefs bool
+}
+
+// aliasStripper renames the aliases in a normalized tree to positional
+// names: tables t1, t2, ... in the order their aliases appear in the query,
+// and columns c1, c2, ... by their position in the SELECT list.
+type aliasStripper struct {
+	scopes []*aliasScope
+	tables int
+	// the table aliases replaced, as they were written.
+	aliases map[string]bool
+}
+
+// stripAliases replaces the table and column aliases in node, and the
+// references to them, with positional names.  The table aliases replaced
+// are returned.
+func stripAliases(node sqlparser.SQLNode) map[string]bool {
+	s := &aliasStripper{aliases: make(map[string]bool)}
+	s.walk(node)
+	return s.aliases
+}
+
+// removeAliasTables returns tables without the aliases among them, which
+// were reported because columns were qualified with them.  An alias that's
+// also the name of a table in node is kept.
+func removeAliasTables(tables []string, aliases map[string]bool, node sqlparser.SQLNode) []string {
+	if len(aliases) == 0 {
+		return tables
+	}
+	names := make(map[string]bool)
+	Walk(node, func(node sqlparser.SQLNode) bool {
+		if node, ok := node.(*sqlparser.TableName); ok {
+			names[trimBackticks(string(node.Name))] = true
+		}
+		return true
+	})
+
+	kept := tables[:0]
+	for _, table := range tables {
+		if name := trimBackticks(table); !aliases[name] || names[name] {
+			kept = append(kept, table)
+		}
+	}
+	return kept
+}
+
+// walk renames the alias references in node, starting a new scope for each
+// SELECT it contains.
+func (s *aliasStripper) walk(node sqlparser.SQLNode) {
+	Walk(node, func(node sqlparser.SQLNode) bool {
+		switch node := node.(type) {
+		case *sqlparser.Select:
+			s.selectStatement(node)
+			return false
+		case *sqlparser.Union:
+			s.selectStatement(node)
+			return false
+		case *sqlparser.Subquery:
+			s.selectStatement(node.Select)
+			return false
+		case *sqlparser.ColName:
+			s.colName(node)
+		case *sqlparser.StarExpr:
+			if table, ok := s.table(string(node.TableName)); ok {
+				node.TableName = []rune(table)
+			}
+		}
+		return true
+	})
+}
+
+// selectStatement strips the aliases from a SELECT or UNION, returning the
+// column aliases its result set is given, mapped to their new names.
+func (s *aliasStripper) selectStatement(node sqlparser.SelectStatement) map[string]string {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		return s.selectNode(node)
+	case *sqlparser.Union:
+		// the first branch names the union's columns.
+		branches, _ := flattenUnion(node)
+		columns := s.selectStatement(branches[0])
+		for _, branch := range branches[1:] {
+			s.selectStatement(branch)
+		}
+		return columns
+	case *UnionRepeat:
+		return s.selectStatement(node.Branch)
+	}
+	return nil
+}
+
+func (s *aliasStripper) selectNode(node *sqlparser.Select) map[string]string {
+	scope := &aliasScope{
+		tables:  make(map[string]string),
+		columns: make(map[string]string),
+		derived: make(map[string]map[string]string),
+	}
+	s.scopes = append(s.scopes, scope)
+	defer func() { s.scopes = s.scopes[:len(s.scopes)-1] }()
+
+	// the FROM clause first, so that the aliases it defines are known
+	// wherever they're used.
+	var conditions []sqlparser.BoolExpr
+	for _, expr := range node.From {
+		conditions = s.tableExpr(expr, scope, conditions)
+	}
+
+	for i, expr := range node.SelectExprs {
+		if expr, ok := expr.(*sqlparser.NonStarExpr); ok && len(expr.As) > 0 {
+			name := "c" + strconv.Itoa(i+1)
+			scope.columns[strings.ToLower(trimBackticks(string(expr.As)))] = name
+			expr.As = []rune(name)
+		}
+	}
+
+	s.walk(node.SelectExprs)
+	for _, cond := range conditions {
+		s.walk(cond)
+	}
+	s.walk(node.Where)
+
+	scope.columnRefs = true
+	for _, expr := range node.GroupBy {
+		s.walk(expr)
+	}
+	s.walk(node.Having)
+	for _, order := range node.OrderBy {
+		s.walk(order)
+	}
+
+	return scope.columns
+}
+
+// tableExpr names the table aliases in a FROM clause's table expression,
+// returning conditions with the expression's join conditions added.
+func (s *aliasStripper) tableExpr(node sqlparser.TableExpr, scope *aliasScope, conditions []sqlparser.BoolExpr) []sqlparser.BoolExpr {
+	switch node := node.(type) {
+	case *sqlparser.AliasedTableExpr:
+		var columns map[string]string
+		if subquery, ok := node.Expr.(*sqlparser.Subquery); ok {
+			columns = s.selectStatement(subquery.Select)
+		}
+		if len(node.As) > 0 {
+			s.tables++
+			name := "t" + strconv.Itoa(s.tables)
+			s.aliases[trimBackticks(string(node.As))] = true
+			scope.tables[trimBackticks(string(node.As))] = name
+			if columns != nil {
+				scope.derived[name] = columns
+				scope.derivedOrder = append(scope.derivedOrder, name)
+			}
+			node.As = []rune(name)
+		}
+	case *sqlparser.ParenTableExpr:
+		conditions = s.tableExpr(node.Expr, scope, conditions)
+	case *sqlparser.JoinTableExpr:
+		conditions = s.tableExpr(node.LeftExpr, scope, conditions)
+		conditions = s.tableExpr(node.RightExpr, scope, conditions)
+		if node.On != nil {
+			conditions = append(conditions, node.On)
+		}
+	}
+	return conditions
+}
+
+// table returns the new name of a table alias, looking in the innermost
+// scope first.
+func (s *aliasStripper) table(alias string) (string, bool) {
+	alias = trimBackticks(alias)
+	for i := len(s.scopes) - 1; i >= 0; i-- {
+		if name, ok := s.scopes[i].tables[alias]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// colName renames a column reference's table alias, and the column itself
+// if it refers to a column alias.
+func (s *aliasStripper) colName(node *sqlparser.ColName) {
+	column := strings.ToLower(trimBackticks(string(node.Name)))
+
+	if len(node.Qualifier) > 0 {
+		table, ok := s.table(string(node.Qualifier))
+		if !ok {
+			return
+		}
+		node.Qualifier = []rune(table)
+		for i := len(s.scopes) - 1; i >= 0; i-- {
+			if columns, ok := s.scopes[i].derived[table]; ok {
+				if name, ok := columns[column]; ok {
+					node.Name = []rune(name)
+				}
+				return
+			}
+		}
+		return
+	}
+
+	if len(s.scopes) == 0 {
+		return
+	}
+	scope := s.scopes[len(s.scopes)-1]
+	if name, ok := scope.columns[column]; ok && scope.columnRefs {
+		node.Name = []rune(name)
+		return
+	}
+	for _, table := range scope.derivedOrder {
+		if name, ok := scope.derived[table][column]; ok {
+			node.Name = []rune(name)
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var stripAliasesTests = []struct {
+	ID             string
+	Inputs         []string
+	ExpectedOutput string
+}{
+	{"table aliases",
+		[]string{
+			"SELECT t0.a, t1.b FROM users t0 JOIN orders AS t1 ON t0.id = t1.user_id WHERE t0.id = 5",
+			"SELECT u.a, o.b FROM users u JOIN orders AS o ON u.id = o.user_id WHERE u.id = 5",
+		},
+		"select t1.a,t2.b from users as t1 join orders as t2 on t1.id = t2.user_id where t1.id = ?",
+	},
+	{"column aliases",
+		[]string{
+			"SELECT a AS x, count(*) AS n FROM t GROUP BY x HAVING n > 1 ORDER BY x",
+			"SELECT a AS col_0, count(*) AS col_1 FROM t GROUP BY col_0 HAVING col_1 > 1 ORDER BY col_0",
+		},
+		"select a as c1,count(*) as c2 from t group by c1 having c2 > 1 order by c1",
+	},
+	{"column alias names aren't replaced in where",
+		[]string{
+			"SELECT a AS x FROM t WHERE x = 1",
+		},
+		"select a as c1 from t where x = ?",
+	},
+	{"unaliased tables",
+		[]string{
+			"SELECT users.a FROM users WHERE users.id = 1",
+		},
+		"select users.a from users where users.id = ?",
+	},
+	{"star",
+		[]string{
+			"SELECT a.* FROM t a",
+			"SELECT b.* FROM t AS b",
+		},
+		"select t1.* from t as t1",
+	},
+	{"correlated subquery",
+		[]string{
+			"SELECT a.x FROM t a WHERE EXISTS (SELECT 1 FROM u b WHERE b.t_id = a.id)",
+			"SELECT p.x FROM t p WHERE EXISTS (SELECT 1 FROM u q WHERE q.t_id = p.id)",
+		},
+		"select t1.x from t as t1 where exists (select ? from u as t2 where t2.t_id = t1.id)",
+	},
+	{"derived table",
+		[]string{
+			"SELECT d.total, total FROM (SELECT sum(x) AS total FROM t) AS d",
+			"SELECT s.sum_x, sum_x FROM (SELECT sum(x) AS sum_x FROM t) AS s",
+		},
+		"select t1.c1,c1 from (select sum(x) as c1 from t) as t1",
+	},
+	{"union",
+		[]string{
+			"SELECT x AS y FROM t UNION SELECT z AS w FROM u",
+		},
+		"select x as c1 from t union select z as c1 from u",
+	},
+}
+
+func TestParserStripAliases(t *testing.T) {
+	n := &normalizer.Parser{StripAliases: true}
+
+	for _, test := range stripAliasesTests {
+		for _, input := range test.Inputs {
+			actual := n.NormalizeQuery(input)
+			if test.ExpectedOutput != actual {
+				t.Error("test '" + test.ID + "' failed normalization of '" + input + "'.  actual = " + actual)
+			}
+		}
+	}
+
+	// aliases are still resolved to their tables in the join graph.
+	n.NormalizeQuery("SELECT * FROM users u JOIN orders o ON u.id = o.user_id")
+	if len(n.LastJoins) != 1 || n.LastJoins[0].LeftTable != "users" || n.LastJoins[0].RightTable != "orders" {
+		t.Errorf("unexpected joins.  actual = %+v", n.LastJoins)
+	}
+	if fmt.Sprint(n.LastTables) != "[orders users]" {
+		t.Error("aliases reported as tables.  actual = " + fmt.Sprint(n.LastTables))
+	}
+	n.NormalizeQuery("SELECT x.a FROM (SELECT a FROM t) AS x")
+	if fmt.Sprint(n.LastTables) != "[t]" {
+		t.Error("derived table alias reported as a table.  actual = " + fmt.Sprint(n.LastTables))
+	}
+
+	// without the option, aliases are kept.
+	n = &normalizer.Parser{}
+	expected := "select u.a as x from users as u"
+	if actual := n.NormalizeQuery("SELECT u.a AS x FROM users u"); expected != actual {
+		t.Error("aliased query failed.  actual = " + actual)
+	}
+}
@@ -1,7 +1,6 @@
 package normalizer
 
 import (
-	"log"
 	"reflect"
 
 	"github.com/honeycombio/sqlparser"
@@ -39,7 +38,7 @@ type transformer interface {
 	TransformTimestampVal(sqlparser.TimestampVal) sqlparser.SQLNode
 	TransformStrVal(sqlparser.StrVal) sqlparser.SQLNode
 	TransformNumVal(sqlparser.NumVal) sqlparser.SQLNode
-	TransformValArg(*sqlparser.ValArg) sqlparser.SQLNode
+	TransformValArg(sqlparser.ValArg) sqlparser.SQLNode
 	TransformValTuple(sqlparser.ValTuple) sqlparser.SQLNode
 	TransformNullVal(*sqlparser.NullVal) sqlparser.SQLNode
 	TransformColName(*sqlparser.ColName) sqlparser.SQLNode
@@ -87,18 +86,24 @@ var (
 	createTableType      reflect.Type = reflect.TypeOf((*sqlparser.CreateTable)(nil))
 	subqueryType         reflect.Type = reflect.TypeOf((*sqlparser.Subquery)(nil))
 	whenType             reflect.Type = reflect.TypeOf((*sqlparser.When)(nil))
+	orderType            reflect.Type = reflect.TypeOf((*sqlparser.Order)(nil))
 
 	nullValType      reflect.Type = reflect.TypeOf((*sqlparser.NullVal)(nil))
 	numValType       reflect.Type = reflect.TypeOf((*sqlparser.NumVal)(nil)).Elem()
 	strValType       reflect.Type = reflect.TypeOf((*sqlparser.StrVal)(nil)).Elem()
 	binaryValType    reflect.Type = reflect.TypeOf((*sqlparser.BinaryVal)(nil)).Elem()
 	timestampValType reflect.Type = reflect.TypeOf((*sqlparser.TimestampVal)(nil)).Elem()
+	valArgType       reflect.Type = reflect.TypeOf((*sqlparser.ValArg)(nil)).Elem()
 	selectExprsType  reflect.Type = reflect.TypeOf((*sqlparser.SelectExprs)(nil)).Elem()
 	updateExprsType  reflect.Type = reflect.TypeOf((*sqlparser.UpdateExprs)(nil)).Elem()
 	updateExprType   reflect.Type = reflect.TypeOf((*sqlparser.UpdateExpr)(nil))
 	valTupleType     reflect.Type = reflect.TypeOf((*sqlparser.ValTuple)(nil)).Elem()
 	valuesType       reflect.Type = reflect.TypeOf((*sqlparser.Values)(nil)).Elem()
 	tableExprsType   reflect.Type = reflect.TypeOf((*sqlparser.TableExprs)(nil)).Elem()
+
+	questionMarkExprType reflect.Type = reflect.TypeOf((*QuestionMarkExpr)(nil))
+	ellipsisExprType     reflect.Type = reflect.TypeOf((*EllipsisExpr)(nil))
+	unionRepeatType      reflect.Type = reflect.TypeOf((*UnionRepeat)(nil))
 )
 
 func transform(node sqlparser.SQLNode, t transformer) sqlparser.SQLNode {
@@ -171,6 +176,8 @@ func transform(node sqlparser.SQLNode, t transformer) sqlparser.SQLNode {
 		return t.TransformTimestampVal(node.(sqlparser.TimestampVal))
 	case binaryValType:
 		return t.TransformBinaryVal(node.(sqlparser.BinaryVal))
+	case valArgType:
+		return t.TransformValArg(node.(sqlparser.ValArg))
 	case selectExprsType:
 		return t.TransformSelectExprs(node.(sqlparser.SelectExprs))
 	case updateExprsType:
@@ -189,10 +196,18 @@ func transform(node sqlparser.SQLNode, t transformer) sqlparser.SQLNode {
 		return t.TransformSubquery(node.(*sqlparser.Subquery))
 	case whenType:
 		return t.TransformWhen(node.(*sqlparser.When))
+	case orderType:
+		return t.TransformOrder(node.(*sqlparser.Order))
 	case otherType:
 		return nil
+	case questionMarkExprType, ellipsisExprType, unionRepeatType:
+		// the normalizer's own nodes, only found in a tree that's already
+		// been normalized.
+		return node
 	default:
-		log.Printf("ast node not handled %+v", reflect.TypeOf(node))
+		if n, ok := t.(*Parser); ok {
+			n.logger().Debug("ast node not handled", "type", nodeType)
+		}
 		return nil
 	}
 }
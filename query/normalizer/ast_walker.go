@@ -0,0 +1,366 @@
+package normalizer
+
+import (
+	"github.com/honeycombio/sqlparser"
+)
+
+// Walk calls visit for node and each of its descendants, depth first.  If
+// visit returns false the node's children are skipped.  Unlike the
+// normalizer's transforms, Walk never modifies the tree and visits every
+// clause, including GROUP BY, HAVING and ORDER BY.
+func Walk(node sqlparser.SQLNode, visit func(node sqlparser.SQLNode) bool) {
+	transform(node, &walker{visit: visit})
+}
+
+// walker is a transformer that leaves the tree as is, calling visit on
+// every node it passes through.
+type walker struct {
+	visit func(node sqlparser.SQLNode) bool
+}
+
+func (w *walker) TransformSelect(node *sqlparser.Select) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.SelectExprs, w)
+	transform(node.From, w)
+	transform(node.Where, w)
+	for _, expr := range node.GroupBy {
+		transform(expr, w)
+	}
+	transform(node.Having, w)
+	for _, order := range node.OrderBy {
+		transform(order, w)
+	}
+	transform(node.Limit, w)
+	return node
+}
+func (w *walker) TransformSelectExprs(node sqlparser.SelectExprs) sqlparser.SQLNode {
+	if !w.visit(node) {
+		return node
+	}
+	for _, se := range node {
+		transform(se, w)
+	}
+	return node
+}
+func (w *walker) TransformUnion(node *sqlparser.Union) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.Right, w)
+	return node
+}
+func (w *walker) TransformInsert(node *sqlparser.Insert) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Table, w)
+	if node.Columns != nil {
+		transform(sqlparser.SelectExprs(node.Columns), w)
+	}
+	transform(node.Rows, w)
+	if node.OnDup != nil {
+		transform(sqlparser.UpdateExprs(node.OnDup), w)
+	}
+	return node
+}
+func (w *walker) TransformUpdate(node *sqlparser.Update) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Table, w)
+	transform(node.Exprs, w)
+	transform(node.Where, w)
+	for _, order := range node.OrderBy {
+		transform(order, w)
+	}
+	transform(node.Limit, w)
+	return node
+}
+func (w *walker) TransformUpdateExprs(node sqlparser.UpdateExprs) sqlparser.SQLNode {
+	if !w.visit(node) {
+		return node
+	}
+	for _, ue := range node {
+		transform(ue, w)
+	}
+	return node
+}
+func (w *walker) TransformUpdateExpr(node *sqlparser.UpdateExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Name, w)
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformDelete(node *sqlparser.Delete) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Table, w)
+	transform(node.Where, w)
+	for _, order := range node.OrderBy {
+		transform(order, w)
+	}
+	transform(node.Limit, w)
+	return node
+}
+func (w *walker) TransformSet(node *sqlparser.Set) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Exprs, w)
+	return node
+}
+func (w *walker) TransformDDL(node *sqlparser.DDL) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformColumnDefinition(node *sqlparser.ColumnDefinition) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformCreateTable(node *sqlparser.CreateTable) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformStarExpr(node *sqlparser.StarExpr) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformNonStarExpr(node *sqlparser.NonStarExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformAliasedTableExpr(node *sqlparser.AliasedTableExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformTableName(node *sqlparser.TableName) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformParenTableExpr(node *sqlparser.ParenTableExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformJoinTableExpr(node *sqlparser.JoinTableExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.LeftExpr, w)
+	transform(node.RightExpr, w)
+	transform(node.On, w)
+	return node
+}
+func (w *walker) TransformWhere(node *sqlparser.Where) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformIndexHints(node *sqlparser.IndexHints) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformAndExpr(node *sqlparser.AndExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.Right, w)
+	return node
+}
+func (w *walker) TransformOrExpr(node *sqlparser.OrExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.Right, w)
+	return node
+}
+func (w *walker) TransformNotExpr(node *sqlparser.NotExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformParenBoolExpr(node *sqlparser.ParenBoolExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformComparisonExpr(node *sqlparser.ComparisonExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.Right, w)
+	return node
+}
+func (w *walker) TransformRangeCond(node *sqlparser.RangeCond) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.From, w)
+	transform(node.To, w)
+	return node
+}
+func (w *walker) TransformExistsExpr(node *sqlparser.ExistsExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Subquery, w)
+	return node
+}
+func (w *walker) TransformBinaryVal(node sqlparser.BinaryVal) sqlparser.SQLNode {
+	w.visit(node)
+	return node
+}
+func (w *walker) TransformTimestampVal(node sqlparser.TimestampVal) sqlparser.SQLNode {
+	w.visit(node)
+	return node
+}
+func (w *walker) TransformStrVal(node sqlparser.StrVal) sqlparser.SQLNode {
+	w.visit(node)
+	return node
+}
+func (w *walker) TransformNumVal(node sqlparser.NumVal) sqlparser.SQLNode {
+	w.visit(node)
+	return node
+}
+func (w *walker) TransformValArg(node sqlparser.ValArg) sqlparser.SQLNode {
+	w.visit(node)
+	return node
+}
+func (w *walker) TransformValTuple(node sqlparser.ValTuple) sqlparser.SQLNode {
+	if !w.visit(node) {
+		return node
+	}
+	for _, val := range node {
+		transform(val, w)
+	}
+	return node
+}
+func (w *walker) TransformNullVal(node *sqlparser.NullVal) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformColName(node *sqlparser.ColName) sqlparser.SQLNode {
+	if node != nil {
+		w.visit(node)
+	}
+	return node
+}
+func (w *walker) TransformSubquery(node *sqlparser.Subquery) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Select, w)
+	return node
+}
+func (w *walker) TransformBinaryExpr(node *sqlparser.BinaryExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Left, w)
+	transform(node.Right, w)
+	return node
+}
+func (w *walker) TransformUnaryExpr(node *sqlparser.UnaryExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformFuncExpr(node *sqlparser.FuncExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Exprs, w)
+	return node
+}
+func (w *walker) TransformCaseExpr(node *sqlparser.CaseExpr) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	for _, when := range node.Whens {
+		transform(when, w)
+	}
+	transform(node.Else, w)
+	return node
+}
+func (w *walker) TransformWhen(node *sqlparser.When) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Cond, w)
+	transform(node.Val, w)
+	return node
+}
+func (w *walker) TransformOrder(node *sqlparser.Order) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Expr, w)
+	return node
+}
+func (w *walker) TransformLimit(node *sqlparser.Limit) sqlparser.SQLNode {
+	if node == nil || !w.visit(node) {
+		return node
+	}
+	transform(node.Offset, w)
+	transform(node.Rowcount, w)
+	return node
+}
+func (w *walker) TransformValues(node sqlparser.Values) sqlparser.SQLNode {
+	if !w.visit(node) {
+		return node
+	}
+	for _, rt := range node {
+		transform(rt, w)
+	}
+	return node
+}
+func (w *walker) TransformTableExprs(node sqlparser.TableExprs) sqlparser.SQLNode {
+	if !w.visit(node) {
+		return node
+	}
+	for _, te := range node {
+		transform(te, w)
+	}
+	return node
+}
@@ -0,0 +1,93 @@
+package normalizer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// Backend is a SQL grammar a Parser can normalize queries with.  Whatever
+// the grammar, a backend must honor the Parser's options and fill in the
+// Parser's Result exactly as SQLParserBackend does, so that swapping
+// backends doesn't change fingerprints.  normalizertest.TestBackend checks
+// a backend against SQLParserBackend's behavior.
+//
+// A backend that can build a sqlparser tree, by parsing q.Folded or by
+// translating its own tree, can have the Parser normalize it with
+// NormalizeStatement and Serialize, as SQLParserBackend does.
+type Backend interface {
+	// Normalize parses q and returns its normalized form, recording what
+	// it finds in n.Result.  n.Result has been reset, with any comment tags
+	// and optimizer hints already filled in, and the Parser sorts its lists
+	// afterwards.  If q can't be parsed an error is returned and n falls
+	// back to the Scanner.
+	Normalize(n *Parser, q BackendQuery) (string, error)
+}
+
+// BackendQuery is a query as a Parser hands it to its Backend.
+type BackendQuery struct {
+	// Text is the query as written, with its executable comments expanded.
+	Text string
+	// Folded is Text with its keywords lowercased, and its identifiers too
+	// unless the Parser's PreserveIdentifierCase is set.  A REPLACE or
+	// INSERT IGNORE is rewritten as a plain INSERT.
+	Folded string
+	// Variant is "replace" or "insert ignore" if Folded was rewritten as a
+	// plain INSERT.
+	Variant string
+}
+
+// newBackendQuery returns q as it's handed to a Backend.
+func (n *Parser) newBackendQuery(q string) BackendQuery {
+	// sqlparser only recognizes lowercase keywords.
+	var folded string
+	if n.PreserveIdentifierCase {
+		folded = foldKeywords(q)
+	} else {
+		folded = strings.ToLower(q)
+	}
+	folded, variant := rewriteInsertVariant(folded)
+	return BackendQuery{Text: q, Folded: folded, Variant: variant}
+}
+
+// SQLParserBackend parses queries with github.com/honeycombio/sqlparser.
+// It's the backend Parsers use by default.
+type SQLParserBackend struct{}
+
+func (SQLParserBackend) Normalize(n *Parser, q BackendQuery) (string, error) {
+	newAST, err := n.parseSQL(q)
+	if err != nil {
+		return "", err
+	}
+	if newAST == nil {
+		return "", nil
+	}
+
+	start := time.Now()
+	normalized := n.serialize(newAST, len(q.Text))
+	n.observe(PhaseSerialize, start)
+	return normalized, nil
+}
+
+// Serialize returns the SQL text of node, a tree NormalizeStatement
+// returned, with the optimizer hints and INSERT variant sqlparser can't
+// represent put back.
+func (n *Parser) Serialize(node sqlparser.SQLNode) string {
+	return n.serialize(node, 0)
+}
+
+func (n *Parser) serialize(node sqlparser.SQLNode, size int) string {
+	normalized := spliceHints(string(sqlparser.Serialize(node, size)), n.leadingHints)
+	return restoreInsertVariant(normalized, n.insertVariant)
+}
+
+// spliceHints puts optimizer hints back after a serialized statement's first
+// keyword, where sqlparser dropped them from.
+func spliceHints(normalized, hints string) string {
+	if hints == "" {
+		return normalized
+	}
+	keywordEnd := strings.IndexByte(normalized, ' ') + 1
+	return normalized[:keywordEnd] + hints + " " + normalized[keywordEnd:]
+}
@@ -0,0 +1,40 @@
+package normalizer_test
+
+import (
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/mysqltools/query/normalizer/normalizertest"
+	"github.com/honeycombio/sqlparser"
+)
+
+// externalBackend parses queries with sqlparser itself, using only what's
+// exported to backends, to check that a backend outside the package can
+// behave like SQLParserBackend.
+type externalBackend struct{}
+
+func (externalBackend) Normalize(n *normalizer.Parser, q normalizer.BackendQuery) (string, error) {
+	stmt, err := sqlparser.Parse(q.Folded)
+	if err != nil {
+		return "", err
+	}
+	node := n.NormalizeStatement(stmt, q.Variant)
+	if node == nil {
+		return "", nil
+	}
+	return n.Serialize(node), nil
+}
+
+// backends are the backends the conformance tests run against.
+var backends = map[string]normalizer.Backend{
+	"sqlparser": normalizer.SQLParserBackend{},
+	"external":  externalBackend{},
+}
+
+func TestBackendConformance(t *testing.T) {
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			normalizertest.TestBackend(t, backend)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package normalizer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache is a size-bounded cache of normalized queries, keyed by the raw
+// query, for Parsers that see the same queries over and over.  A hit
+// returns the query's normalized form and fills in the Parser's Result as
+// normalizing it would have.  When the cache is full, the least recently
+// used query is evicted.
+//
+// A Cache is safe for concurrent use, so one can be shared by the Parsers
+// of many goroutines, but only by Parsers with the same options: a cached
+// result reflects the options of the Parser that normalized it.
+type Cache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds *cacheEntry, most recently used first.
+	lru   *list.List
+	stats CacheStats
+}
+
+// CacheStats are a Cache's hit and miss counts.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	// Len is the number of queries in the cache.
+	Len int
+}
+
+type cacheEntry struct {
+	query      string
+	normalized string
+	result     Result
+}
+
+// NewCache returns a Cache holding up to size queries.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+	return &Cache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the cache's statistics so far.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Len = c.lru.Len()
+	return stats
+}
+
+// get returns the normalized form and Result cached for query.
+func (c *Cache) get(query string) (string, Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[query]
+	if !ok {
+		c.stats.Misses++
+		return "", Result{}, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	return entry.normalized, entry.result.clone(), true
+}
+
+// add caches the normalized form and Result of query.
+func (c *Cache) add(query, normalized string, result Result) {
+	entry := &cacheEntry{query: query, normalized: normalized, result: result.clone()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[query]; ok {
+		// another Parser normalized the query at the same time.
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).query)
+		c.stats.Evictions++
+	}
+}
+
+// cacheable returns true if the result of normalizing q only depends on q
+// and the Parser's options, so can be cached.
+func cacheable(q string) bool {
+	switch firstWord(q) {
+	case "prepare", "execute", "deallocate", "drop":
+		// these read and change the Parser's Session.
+		return false
+	}
+	return true
+}
+
+// clone returns a copy of r that shares none of its lists or maps.
+func (r Result) clone() Result {
+	c := r
+	c.LastTables = copyStrings(r.LastTables)
+	c.LastComments = copyStrings(r.LastComments)
+	c.LastINListSizes = append(make([]int, 0, len(r.LastINListSizes)), r.LastINListSizes...)
+	c.LastCommentTags = copyStringMap(r.LastCommentTags)
+	c.LastHints = make([]OptimizerHint, len(r.LastHints))
+	for i, hint := range r.LastHints {
+		hint.Args = copyStrings(hint.Args)
+		c.LastHints[i] = hint
+	}
+	c.LastMetrics.Joins = copyIntMap(r.LastMetrics.Joins)
+	c.LastJoins = make([]JoinEdge, len(r.LastJoins))
+	for i, join := range r.LastJoins {
+		if join.Columns != nil {
+			join.Columns = append(make([]JoinColumns, 0, len(join.Columns)), join.Columns...)
+		}
+		c.LastJoins[i] = join
+	}
+	c.LastFunctions = copyStrings(r.LastFunctions)
+	c.LastNondeterministic = copyStrings(r.LastNondeterministic)
+	c.LastLimits = append(make([]LimitValues, 0, len(r.LastLimits)), r.LastLimits...)
+	c.LastBodyStatements = copyStrings(r.LastBodyStatements)
+	c.LastVariables = append(make([]Variable, 0, len(r.LastVariables)), r.LastVariables...)
+	c.LastOnDupColumns = copyStrings(r.LastOnDupColumns)
+	return c
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
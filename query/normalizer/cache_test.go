@@ -0,0 +1,149 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var cacheTests = []struct {
+	ID      string
+	Options normalizer.Parser
+	Input   string
+}{
+	{"parsed",
+		normalizer.Parser{},
+		"SELECT /* app:web */ a FROM t JOIN u ON t.id = u.t_id WHERE x IN (1, 2)",
+	},
+	{"parse error",
+		normalizer.Parser{},
+		"SELECT * FROM t WHERE",
+	},
+	{"too long",
+		normalizer.Parser{MaxQueryLength: 20},
+		"SELECT a FROM t WHERE id IN (1, 2)",
+	},
+	{"panic",
+		normalizer.Parser{Backend: panicBackend{}},
+		"SELECT a FROM t WHERE id = 1",
+	},
+}
+
+func TestParserCache(t *testing.T) {
+	for _, test := range cacheTests {
+		uncached := test.Options
+		expected := uncached.NormalizeQuery(test.Input)
+
+		// a hit replays the miss, fallback and all.
+		n := test.Options
+		n.Cache = normalizer.NewCache(10)
+		n.NormalizeQuery(test.Input)
+		actual := n.NormalizeQuery(test.Input)
+		if expected != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if fmt.Sprintf("%+v", uncached.Result) != fmt.Sprintf("%+v", n.Result) {
+			t.Error("test '" + test.ID + "' failed result.  actual = " + fmt.Sprintf("%+v", n.Result))
+		}
+		if stats := n.Cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+			t.Errorf("test '"+test.ID+"' failed stats.  actual = %+v", stats)
+		}
+	}
+}
+
+func TestParserCacheSkipsTimeouts(t *testing.T) {
+	cache := normalizer.NewCache(10)
+	n := &normalizer.Parser{Backend: slowBackend{time.Second}, Timeout: 10 * time.Millisecond, Cache: cache}
+
+	n.NormalizeQuery("SELECT a FROM t WHERE id = 1")
+	if n.LastFallback != normalizer.FallbackTimeout || cache.Stats().Len != 0 {
+		t.Errorf("timed out query was cached.  actual = %+v", cache.Stats())
+	}
+}
+
+func TestParserCacheIsolation(t *testing.T) {
+	n := &normalizer.Parser{Cache: normalizer.NewCache(10)}
+	q := "SELECT a FROM t JOIN u ON t.id = u.t_id"
+
+	n.NormalizeQuery(q)
+	n.LastTables[0] = "changed"
+	n.LastMetrics.Joins["changed"] = 1
+
+	n.NormalizeQuery(q)
+	if fmt.Sprint(n.LastTables) != "[t u]" || len(n.LastMetrics.Joins) != 1 {
+		t.Errorf("cached result was changed.  actual = %+v", n.Result)
+	}
+}
+
+func TestParserCacheEviction(t *testing.T) {
+	cache := normalizer.NewCache(2)
+	n := &normalizer.Parser{Cache: cache}
+
+	n.NormalizeQuery("SELECT 1 FROM a")
+	n.NormalizeQuery("SELECT 1 FROM b")
+	n.NormalizeQuery("SELECT 1 FROM a") // hit, b is now the least recently used
+	n.NormalizeQuery("SELECT 1 FROM c") // evicts b
+	n.NormalizeQuery("SELECT 1 FROM a") // hit
+	n.NormalizeQuery("SELECT 1 FROM b") // miss, evicts c
+
+	expected := normalizer.CacheStats{Hits: 2, Misses: 4, Evictions: 2, Len: 2}
+	if actual := cache.Stats(); expected != actual {
+		t.Errorf("unexpected stats.  actual = %+v", actual)
+	}
+}
+
+func TestParserCacheSkipsPrepared(t *testing.T) {
+	cache := normalizer.NewCache(10)
+	n := &normalizer.Parser{Cache: cache}
+
+	n.NormalizeQuery("PREPARE stmt FROM 'SELECT * FROM t WHERE id = ?'")
+	n.NormalizeQuery("EXECUTE stmt USING @id")
+	n.NormalizeQuery("EXECUTE stmt USING @id")
+
+	expected := normalizer.CacheStats{}
+	if actual := cache.Stats(); expected != actual {
+		t.Errorf("unexpected stats.  actual = %+v", actual)
+	}
+	if n.LastPrepared.Query != "select * from t where id = ?" {
+		t.Errorf("unexpected prepared statement.  actual = %+v", n.LastPrepared)
+	}
+}
+
+func TestParserCacheConcurrency(t *testing.T) {
+	cache := normalizer.NewCache(4)
+	queries := []string{
+		"SELECT a FROM t WHERE id = 1",
+		"SELECT b FROM u WHERE id IN (1, 2)",
+		"UPDATE t SET a = 1 WHERE id = 2",
+		"DELETE FROM u WHERE id = 3",
+		"INSERT INTO t (a) VALUES (1)",
+	}
+	expected := make([]string, len(queries))
+	for i, q := range queries {
+		expected[i] = (&normalizer.Parser{}).NormalizeQuery(q)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := &normalizer.Parser{Cache: cache}
+			for i := 0; i < 100; i++ {
+				j := i % len(queries)
+				if actual := n.NormalizeQuery(queries[j]); actual != expected[j] {
+					t.Error("concurrent normalization failed.  actual = " + actual)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits+stats.Misses != 800 || stats.Len != 4 {
+		t.Errorf("unexpected stats.  actual = %+v", stats)
+	}
+}
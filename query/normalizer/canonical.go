@@ -0,0 +1,197 @@
+package normalizer
+
+import (
+	"sort"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// mirroredOperators maps each comparison operator whose sides can be swapped
+// to the operator that compares the swapped sides the same way.
+var mirroredOperators = map[string]string{
+	sqlparser.AST_EQ:  sqlparser.AST_EQ,
+	sqlparser.AST_NE:  sqlparser.AST_NE,
+	sqlparser.AST_NSE: sqlparser.AST_NSE,
+	sqlparser.AST_LT:  sqlparser.AST_GT,
+	sqlparser.AST_GT:  sqlparser.AST_LT,
+	sqlparser.AST_LE:  sqlparser.AST_GE,
+	sqlparser.AST_GE:  sqlparser.AST_LE,
+}
+
+// negatedOperators maps each comparison operator to the operator NOT turns
+// it into.  A comparison is NULL exactly when its negation is, so the pairs
+// are equivalent under NOT.
+var negatedOperators = map[string]string{
+	sqlparser.AST_EQ:       sqlparser.AST_NE,
+	sqlparser.AST_NE:       sqlparser.AST_EQ,
+	sqlparser.AST_LT:       sqlparser.AST_GE,
+	sqlparser.AST_GE:       sqlparser.AST_LT,
+	sqlparser.AST_GT:       sqlparser.AST_LE,
+	sqlparser.AST_LE:       sqlparser.AST_GT,
+	sqlparser.AST_IN:       sqlparser.AST_NOT_IN,
+	sqlparser.AST_NOT_IN:   sqlparser.AST_IN,
+	sqlparser.AST_IS:       sqlparser.AST_IS_NOT,
+	sqlparser.AST_IS_NOT:   sqlparser.AST_IS,
+	sqlparser.AST_LIKE:     sqlparser.AST_NOT_LIKE,
+	sqlparser.AST_NOT_LIKE: sqlparser.AST_LIKE,
+}
+
+// canonicalJoin returns the join type MySQL treats join as a synonym of.
+// JOIN, INNER JOIN and CROSS JOIN are all the same join.
+func canonicalJoin(join string) string {
+	switch join {
+	case sqlparser.AST_INNER_JOIN, sqlparser.AST_CROSS_JOIN:
+		return sqlparser.AST_JOIN
+	}
+	return join
+}
+
+// canonicalAnd transforms the terms of an AND chain and joins them back
+// together in sorted order.
+func (n *Parser) canonicalAnd(node *sqlparser.AndExpr) sqlparser.BoolExpr {
+	terms := n.sortTerms(flattenAnd(node, nil))
+	expr := terms[0]
+	for _, term := range terms[1:] {
+		expr = &sqlparser.AndExpr{Left: expr, Right: term}
+	}
+	return expr
+}
+
+// canonicalOr is canonicalAnd for an OR chain.
+func (n *Parser) canonicalOr(node *sqlparser.OrExpr) sqlparser.BoolExpr {
+	terms := n.sortTerms(flattenOr(node, nil))
+	expr := terms[0]
+	for _, term := range terms[1:] {
+		expr = &sqlparser.OrExpr{Left: expr, Right: term}
+	}
+	return expr
+}
+
+// flattenAnd appends the terms of an AND chain to terms.  Parentheses that
+// don't change the chain's meaning are dropped, so "(a and b) and (c)" has
+// the terms a, b and c.
+func flattenAnd(node sqlparser.BoolExpr, terms []sqlparser.BoolExpr) []sqlparser.BoolExpr {
+	switch node := node.(type) {
+	case *sqlparser.AndExpr:
+		return flattenAnd(node.Right, flattenAnd(node.Left, terms))
+	case *sqlparser.ParenBoolExpr:
+		if _, ok := node.Expr.(*sqlparser.OrExpr); !ok {
+			return flattenAnd(node.Expr, terms)
+		}
+	}
+	return append(terms, node)
+}
+
+// flattenOr is flattenAnd for an OR chain.  Parentheses around an AND term
+// are kept, though they aren't needed.
+func flattenOr(node sqlparser.BoolExpr, terms []sqlparser.BoolExpr) []sqlparser.BoolExpr {
+	switch node := node.(type) {
+	case *sqlparser.OrExpr:
+		return flattenOr(node.Right, flattenOr(node.Left, terms))
+	case *sqlparser.ParenBoolExpr:
+		if _, ok := node.Expr.(*sqlparser.AndExpr); !ok {
+			return flattenOr(node.Expr, terms)
+		}
+	}
+	return append(terms, node)
+}
+
+// sortTerms transforms each term of a chain, then sorts them by their
+// normalized text.
+func (n *Parser) sortTerms(terms []sqlparser.BoolExpr) []sqlparser.BoolExpr {
+	type term struct {
+		expr sqlparser.BoolExpr
+		key  string
+	}
+	sorted := make([]term, len(terms))
+	for i, expr := range terms {
+		expr, _ = transform(expr, n).(sqlparser.BoolExpr)
+		sorted[i] = term{expr, string(sqlparser.Serialize(expr, 0))}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+	for i := range sorted {
+		terms[i] = sorted[i].expr
+	}
+	return terms
+}
+
+// canonicalComparison puts the sides of a symmetric comparison in order,
+// expressions before constants, so "? = a" becomes "a = ?" and "b.y = a.x"
+// becomes "a.x = b.y".
+func canonicalComparison(node *sqlparser.ComparisonExpr) {
+	mirrored, ok := mirroredOperators[node.Operator]
+	if !ok {
+		return
+	}
+	if compareOperands(node.Right, node.Left) < 0 {
+		node.Left, node.Right = node.Right, node.Left
+		node.Operator = mirrored
+	}
+}
+
+// compareOperands orders two sides of a comparison, returning a negative
+// number if a comes first.
+func compareOperands(a, b sqlparser.ValExpr) int {
+	if ca, cb := isConstant(a), isConstant(b); ca != cb {
+		if cb {
+			return -1
+		}
+		return 1
+	}
+	sa := string(sqlparser.Serialize(a, 0))
+	sb := string(sqlparser.Serialize(b, 0))
+	switch {
+	case sa < sb:
+		return -1
+	case sa > sb:
+		return 1
+	}
+	return 0
+}
+
+// isConstant returns true if node is a literal or a placeholder.
+func isConstant(node sqlparser.ValExpr) bool {
+	switch node.(type) {
+	case *QuestionMarkExpr, *EllipsisExpr, *sqlparser.NullVal,
+		sqlparser.StrVal, sqlparser.NumVal, sqlparser.BinaryVal,
+		sqlparser.TimestampVal, sqlparser.ValArg, sqlparser.ListArg:
+		return true
+	}
+	return false
+}
+
+// canonicalNot pushes a NOT into the expression it negates where there's an
+// operator for the negation, so "not a is null" becomes "a is not null" and
+// "not not a" becomes "a".  Otherwise node is returned as is.
+func canonicalNot(node *sqlparser.NotExpr) sqlparser.BoolExpr {
+	expr := node.Expr
+	for {
+		paren, ok := expr.(*sqlparser.ParenBoolExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+
+	switch expr := expr.(type) {
+	case *sqlparser.NotExpr:
+		return expr.Expr
+	case *sqlparser.ComparisonExpr:
+		if negated, ok := negatedOperators[expr.Operator]; ok {
+			expr.Operator = negated
+			return expr
+		}
+	case *sqlparser.RangeCond:
+		switch expr.Operator {
+		case sqlparser.AST_BETWEEN:
+			expr.Operator = sqlparser.AST_NOT_BETWEEN
+			return expr
+		case sqlparser.AST_NOT_BETWEEN:
+			expr.Operator = sqlparser.AST_BETWEEN
+			return expr
+		}
+	}
+	return node
+}
@@ -0,0 +1,131 @@
+package normalizer_test
+
+import (
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var canonicalTests = []struct {
+	ID             string
+	Inputs         []string
+	ExpectedOutput string
+}{
+	{"and terms",
+		[]string{
+			"SELECT * FROM t WHERE a = 1 AND b = 2",
+			"SELECT * FROM t WHERE b = 2 AND a = 1",
+			"SELECT * FROM t WHERE (b = 2) AND (a = 1)",
+		},
+		"select * from t where a = ? and b = ?",
+	},
+	{"nested chains",
+		[]string{
+			"SELECT * FROM t WHERE c = 3 AND (b = 2 AND a = 1)",
+			"SELECT * FROM t WHERE (a = 1 AND c = 3) AND b = 2",
+		},
+		"select * from t where a = ? and b = ? and c = ?",
+	},
+	{"or terms",
+		[]string{
+			"SELECT * FROM t WHERE z = 1 AND (y = 2 OR x = 3)",
+			"SELECT * FROM t WHERE (x = 3 OR y = 2) AND z = 1",
+		},
+		"select * from t where (x = ? or y = ?) and z = ?",
+	},
+	{"and inside or",
+		[]string{
+			"SELECT * FROM t WHERE (b = 1 AND a = 2) OR c = 3",
+			"SELECT * FROM t WHERE c = 3 OR (a = 2 AND b = 1)",
+		},
+		"select * from t where (a = ? and b = ?) or c = ?",
+	},
+	{"comparison sides",
+		[]string{
+			"SELECT * FROM t WHERE 5 = a AND 3 < b",
+			"SELECT * FROM t WHERE a = 5 AND b > 3",
+		},
+		"select * from t where a = ? and b > ?",
+	},
+	{"join condition",
+		[]string{
+			"SELECT * FROM a JOIN b ON a.id = b.a_id",
+			"SELECT * FROM a INNER JOIN b ON b.a_id = a.id",
+			"SELECT * FROM a CROSS JOIN b ON a.id = b.a_id",
+		},
+		"select * from a join b on a.id = b.a_id",
+	},
+	{"not equal",
+		[]string{
+			"SELECT * FROM t WHERE a != 1",
+			"SELECT * FROM t WHERE a <> 1",
+			"SELECT * FROM t WHERE NOT a = 1",
+			"SELECT * FROM t WHERE 1 <> a",
+		},
+		"select * from t where a != ?",
+	},
+	{"is not null",
+		[]string{
+			"SELECT * FROM t WHERE a IS NOT NULL",
+			"SELECT * FROM t WHERE NOT a IS NULL",
+			"SELECT * FROM t WHERE NOT (a IS NULL)",
+			"SELECT * FROM t WHERE NOT NOT a IS NOT NULL",
+		},
+		"select * from t where a is not null",
+	},
+	{"negated in and between",
+		[]string{
+			"SELECT * FROM t WHERE NOT a IN (1, 2) AND NOT b BETWEEN 1 AND 2",
+			"SELECT * FROM t WHERE b NOT BETWEEN 1 AND 2 AND a NOT IN (1, 2, 3)",
+		},
+		"select * from t where a not in (...) and b not between ? and ?",
+	},
+	{"negated range",
+		[]string{
+			"SELECT * FROM t WHERE NOT a < 1",
+			"SELECT * FROM t WHERE 1 <= a",
+		},
+		"select * from t where a >= ?",
+	},
+	{"not without a negation",
+		[]string{
+			"SELECT * FROM t WHERE NOT EXISTS (SELECT 1 FROM u WHERE u.b = 1 AND u.a = 2)",
+		},
+		"select * from t where not exists (select ? from u where u.a = ? and u.b = ?)",
+	},
+	{"update",
+		[]string{
+			"UPDATE t SET a = 1 WHERE c = 3 AND b = 2",
+			"UPDATE t SET a = 1 WHERE b = 2 AND c = 3",
+		},
+		"update t set a = ? where b = ? and c = ?",
+	},
+}
+
+func TestParserCanonicalPredicates(t *testing.T) {
+	n := &normalizer.Parser{CanonicalPredicates: true}
+
+	for _, test := range canonicalTests {
+		for _, input := range test.Inputs {
+			actual := n.NormalizeQuery(input)
+			if test.ExpectedOutput != actual {
+				t.Error("test '" + test.ID + "' failed normalization of '" + input + "'.  actual = " + actual)
+			}
+		}
+	}
+
+	// without the option, operands are left where they were.
+	n = &normalizer.Parser{}
+	expected := "select * from a inner join b on b.a_id = a.id where b = ? and not a is null"
+	if actual := n.NormalizeQuery("SELECT * FROM a INNER JOIN b ON b.a_id = a.id WHERE b = 2 AND NOT a IS NULL"); expected != actual {
+		t.Error("uncanonical query failed.  actual = " + actual)
+	}
+}
+
+func TestParserCanonicalJoinMetrics(t *testing.T) {
+	n := &normalizer.Parser{CanonicalPredicates: true}
+	n.NormalizeQuery("SELECT * FROM a INNER JOIN b ON a.id = b.a_id JOIN c ON c.b_id = b.id")
+	if n.LastMetrics.Joins["join"] != 2 || len(n.LastMetrics.Joins) != 1 {
+		t.Errorf("unexpected join metrics.  actual = %v", n.LastMetrics.Joins)
+	}
+}
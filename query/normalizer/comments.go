@@ -0,0 +1,232 @@
+package normalizer
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ParseCommentTags decodes the key/value tags in a single comment body (the
+// text between "/*" and "*/").  Both sqlcommenter
+// (key='url%20encoded',key2='value') and Rails marginalia
+// (application:App,action:show) formats are understood.  Comments that
+// aren't entirely made up of tags return nil.
+func ParseCommentTags(comment string) map[string]string {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil
+	}
+
+	if tags := parseSQLCommenterTags(comment); tags != nil {
+		return tags
+	}
+	return parseMarginaliaTags(comment)
+}
+
+// parseSQLCommenterTags decodes comment following the sqlcommenter spec:
+// comma separated key='value' pairs where both key and value are URL
+// encoded, and single quotes inside the value are escaped with a backslash.
+func parseSQLCommenterTags(comment string) map[string]string {
+	tags := make(map[string]string)
+
+	for _, pair := range splitTags(comment) {
+		eq := strings.IndexByte(pair, '=')
+		if eq <= 0 {
+			return nil
+		}
+		key, value := strings.TrimSpace(pair[:eq]), strings.TrimSpace(pair[eq+1:])
+		if !isTagKey(key) || len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+			return nil
+		}
+		value = strings.Replace(value[1:len(value)-1], `\'`, `'`, -1)
+
+		var err error
+		if key, err = url.PathUnescape(key); err != nil {
+			return nil
+		}
+		if value, err = url.PathUnescape(value); err != nil {
+			return nil
+		}
+		tags[key] = value
+	}
+
+	return tags
+}
+
+// parseMarginaliaTags decodes comment following the marginalia format:
+// comma separated key:value pairs.  values may themselves contain colons
+// (e.g. line:/app/models/user.rb:12).
+func parseMarginaliaTags(comment string) map[string]string {
+	tags := make(map[string]string)
+
+	for _, pair := range strings.Split(comment, ",") {
+		colon := strings.IndexByte(pair, ':')
+		if colon <= 0 {
+			return nil
+		}
+		key := strings.TrimSpace(pair[:colon])
+		if !isTagKey(key) {
+			return nil
+		}
+		tags[key] = strings.TrimSpace(pair[colon+1:])
+	}
+
+	return tags
+}
+
+// splitTags splits a sqlcommenter comment on the commas that aren't inside
+// a quoted value.
+func splitTags(comment string) []string {
+	var pairs []string
+	var quoted, escaped bool
+
+	start := 0
+	for i, r := range comment {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '\'':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			pairs = append(pairs, comment[start:i])
+			start = i + 1
+		}
+	}
+	return append(pairs, comment[start:])
+}
+
+func isTagKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\'' || r == '"' || r == '(' || r == ')' {
+			return false
+		}
+	}
+	return true
+}
+
+// extractBlockComments returns the body of every /* ... */ comment in q that
+// isn't inside a quoted string or identifier.
+func extractBlockComments(q string) []string {
+	var comments []string
+	var quote byte
+	var escaped bool
+
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return comments
+			}
+			comments = append(comments, q[i+2:i+2+end])
+			i += 2 + end + 1
+		}
+	}
+
+	return comments
+}
+
+// commentTags merges the tags from every comment body in comments.  Tags in
+// later comments win over earlier ones.
+func commentTags(comments []string) map[string]string {
+	tags := make(map[string]string)
+	for _, c := range comments {
+		for k, v := range ParseCommentTags(c) {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// selectModifiers are the SELECT modifiers mysqldump and others put in
+// executable comments, e.g. "SELECT /*!40001 SQL_NO_CACHE */ * FROM t".
+var selectModifiers = map[string]bool{
+	"sql_no_cache": true, "sql_cache": true, "sql_calc_found_rows": true,
+	"high_priority": true, "straight_join": true, "sql_buffer_result": true,
+	"sql_small_result": true, "sql_big_result": true,
+}
+
+// isSelectModifiers returns true if body is made up of SELECT modifiers
+// alone.
+func isSelectModifiers(body string) bool {
+	words := strings.Fields(body)
+	for _, word := range words {
+		if !selectModifiers[strings.ToLower(word)] {
+			return false
+		}
+	}
+	return len(words) > 0
+}
+
+// expandExecutableComments replaces MySQL executable comments (/*! ... */
+// and the versioned /*!50001 ... */ form) with their contents, since the
+// server executes them as regular SQL.  If modifierHints is set, comments
+// holding only SELECT modifiers, which sqlparser would take for a column,
+// become optimizer hint comments instead.
+func expandExecutableComments(q string, modifierHints bool) string {
+	if !strings.Contains(q, "/*!") {
+		return q
+	}
+
+	var b strings.Builder
+	var quote byte
+	var escaped bool
+
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+			b.WriteByte(c)
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '/' && strings.HasPrefix(q[i:], "/*!"):
+			end := strings.Index(q[i+3:], "*/")
+			if end < 0 {
+				// nothing after an unterminated comment can be closed.
+				b.WriteString(q[i:])
+				return strings.TrimSpace(b.String())
+			}
+			body := strings.TrimSpace(strings.TrimLeft(q[i+3:i+3+end], "0123456789"))
+			if modifierHints && isSelectModifiers(body) {
+				b.WriteString(" /*+ " + body + " */ ")
+			} else {
+				b.WriteByte(' ')
+				b.WriteString(body)
+				b.WriteByte(' ')
+			}
+			i += 3 + end + 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,70 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var commentTagTests = []struct {
+	ID       string
+	Input    string
+	Expected map[string]string
+}{
+	{"sqlcommenter",
+		"controller='index',framework='spring',traceparent='00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01'",
+		map[string]string{"controller": "index", "framework": "spring", "traceparent": "00-5bd66ef5095369c7b0d1f8f4bd33716a-c532cb4098ac3dd2-01"},
+	},
+	{"sqlcommenter url encoding",
+		"route='%2Fparam%2A%27d',db%20driver='go%2Fsql',note='it\\'s%20here'",
+		map[string]string{"route": "/param*'d", "db driver": "go/sql", "note": "it's here"},
+	},
+	{"sqlcommenter commas inside values",
+		"action='a,b',controller='c'",
+		map[string]string{"action": "a,b", "controller": "c"},
+	},
+	{"marginalia",
+		"application:BlogApp,controller:posts,action:show,line:/app/models/post.rb:12",
+		map[string]string{"application": "BlogApp", "controller": "posts", "action": "show", "line": "/app/models/post.rb:12"},
+	},
+	{"plain comment",
+		"insert comment here",
+		nil,
+	},
+	{"optimizer hint",
+		"+ INDEX(t idx)",
+		nil,
+	},
+	{"unquoted sqlcommenter value",
+		"controller=index",
+		nil,
+	},
+}
+
+func TestParseCommentTags(t *testing.T) {
+	for _, test := range commentTagTests {
+		actual := normalizer.ParseCommentTags(test.Input)
+		if fmt.Sprint(test.Expected) != fmt.Sprint(actual) || (test.Expected == nil) != (actual == nil) {
+			t.Error("test '" + test.ID + "' failed comment tag parsing.  actual = " + fmt.Sprint(actual))
+		}
+	}
+}
+
+func TestParserCommentTags(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	actual := n.NormalizeQuery("SELECT /* application:BlogApp */ colname FROM tablename WHERE id = 5 /*controller='Posts',traceparent='00-abc-01'*/")
+	if actual != "select colname from tablename where id = ?" {
+		t.Error("comment tags failed normalization.  actual = " + actual)
+	}
+	expected := map[string]string{"application": "BlogApp", "controller": "Posts", "traceparent": "00-abc-01"}
+	if fmt.Sprint(expected) != fmt.Sprint(n.LastCommentTags) {
+		t.Error("comment tags failed accumulation.  actual = " + fmt.Sprint(n.LastCommentTags))
+	}
+
+	n.NormalizeQuery("SELECT colname FROM tablename WHERE name = '/* action:show */'")
+	if len(n.LastCommentTags) != 0 {
+		t.Error("comment tags inside strings were decoded.  actual = " + fmt.Sprint(n.LastCommentTags))
+	}
+}
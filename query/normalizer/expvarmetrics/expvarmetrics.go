@@ -0,0 +1,115 @@
+// Package expvarmetrics publishes a normalizer's counters and timings with
+// expvar.
+package expvarmetrics
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// Instrumentation is a normalizer.Instrumentation that keeps its counters
+// and timings in an expvar.Map, laid out as:
+//
+//	{
+//	  "parsed": {"select": 10, ...},
+//	  "fallbacks": {"parse_error": {"select": 1, ...}, ...},
+//	  "cached": {"select": 6, ...},
+//	  "nanoseconds": {"parse": {"select": 123456, ...}, ...},
+//	  "observations": {"parse": {"select": 10, ...}, ...}
+//	}
+//
+// Parsed and fallback queries are counted by statement type, those served
+// from a normalizer.Cache in "cached" as well, and the time spent in each
+// phase is totaled by statement type, alongside the number of times the
+// phase was observed to average it by.
+type Instrumentation struct {
+	// Map holds the counters and timings.
+	Map *expvar.Map
+
+	parsed       *expvar.Map
+	fallbacks    *expvar.Map
+	cached       *expvar.Map
+	nanoseconds  *expvar.Map
+	observations *expvar.Map
+
+	// mu guards adding maps to fallbacks, nanoseconds and observations.
+	mu sync.Mutex
+}
+
+// New returns an Instrumentation published under name.  Like expvar.Publish,
+// it panics if name is already in use.
+func New(name string) *Instrumentation {
+	i := newInstrumentation()
+	expvar.Publish(name, i.Map)
+	return i
+}
+
+// NewUnpublished returns an Instrumentation that isn't published, for the
+// caller to publish or read as it likes.
+func NewUnpublished() *Instrumentation {
+	return newInstrumentation()
+}
+
+func newInstrumentation() *Instrumentation {
+	i := &Instrumentation{
+		Map:          new(expvar.Map).Init(),
+		parsed:       new(expvar.Map).Init(),
+		fallbacks:    new(expvar.Map).Init(),
+		cached:       new(expvar.Map).Init(),
+		nanoseconds:  new(expvar.Map).Init(),
+		observations: new(expvar.Map).Init(),
+	}
+	i.Map.Set("parsed", i.parsed)
+	i.Map.Set("fallbacks", i.fallbacks)
+	i.Map.Set("cached", i.cached)
+	i.Map.Set("nanoseconds", i.nanoseconds)
+	i.Map.Set("observations", i.observations)
+	return i
+}
+
+func (i *Instrumentation) Parsed(statement string, cached bool) {
+	i.parsed.Add(statementKey(statement), 1)
+	if cached {
+		i.cached.Add(statementKey(statement), 1)
+	}
+}
+
+func (i *Instrumentation) FellBack(statement string, reason normalizer.FallbackReason, cached bool) {
+	i.sub(i.fallbacks, string(reason)).Add(statementKey(statement), 1)
+	if cached {
+		i.cached.Add(statementKey(statement), 1)
+	}
+}
+
+func (i *Instrumentation) Observe(phase normalizer.Phase, statement string, d time.Duration) {
+	key := statementKey(statement)
+	i.sub(i.nanoseconds, string(phase)).Add(key, int64(d))
+	i.sub(i.observations, string(phase)).Add(key, 1)
+}
+
+// sub returns the map under key in parent, adding it if need be.
+func (i *Instrumentation) sub(parent *expvar.Map, key string) *expvar.Map {
+	if m, ok := parent.Get(key).(*expvar.Map); ok {
+		return m
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if m, ok := parent.Get(key).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	parent.Set(key, m)
+	return m
+}
+
+// statementKey is the key a statement type is counted under.
+func statementKey(statement string) string {
+	if statement == "" {
+		return "unknown"
+	}
+	return statement
+}
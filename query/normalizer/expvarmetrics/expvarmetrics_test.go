@@ -0,0 +1,59 @@
+package expvarmetrics_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/mysqltools/query/normalizer/expvarmetrics"
+)
+
+func TestInstrumentation(t *testing.T) {
+	i := expvarmetrics.New("normalizer_test")
+	n := &normalizer.Parser{Instrumentation: i, Cache: normalizer.NewCache(10)}
+
+	n.NormalizeQuery("SELECT * FROM t WHERE id = 1")
+	n.NormalizeQuery("SELECT * FROM u")
+	n.NormalizeQuery("UPDATE t SET a = 1")
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+	n.NormalizeQuery("SELECT * FROM u")
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+
+	var metrics struct {
+		Parsed       map[string]int64
+		Fallbacks    map[string]map[string]int64
+		Cached       map[string]int64
+		Nanoseconds  map[string]map[string]int64
+		Observations map[string]map[string]int64
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("normalizer_test").String()), &metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if metrics.Parsed["select"] != 3 || metrics.Parsed["update"] != 1 {
+		t.Errorf("unexpected parsed counts.  actual = %v", metrics.Parsed)
+	}
+	if metrics.Fallbacks["parse_error"]["select"] != 2 {
+		t.Errorf("unexpected fallback counts.  actual = %v", metrics.Fallbacks)
+	}
+	if metrics.Cached["select"] != 2 || len(metrics.Cached) != 1 {
+		t.Errorf("unexpected cached counts.  actual = %v", metrics.Cached)
+	}
+	// cache hits take no time.
+	// the query that fell back was parsed, unsuccessfully, too.
+	if metrics.Observations["parse"]["select"] != 3 {
+		t.Errorf("unexpected parse observations.  actual = %v", metrics.Observations["parse"])
+	}
+	for _, phase := range []string{"transform", "serialize"} {
+		if metrics.Observations[phase]["select"] != 2 || metrics.Observations[phase]["update"] != 1 {
+			t.Errorf("unexpected %s observations.  actual = %v", phase, metrics.Observations[phase])
+		}
+		if metrics.Nanoseconds[phase]["select"] <= 0 {
+			t.Errorf("unexpected %s time.  actual = %v", phase, metrics.Nanoseconds[phase])
+		}
+	}
+	if metrics.Observations["scan"]["select"] != 1 {
+		t.Errorf("unexpected scan observations.  actual = %v", metrics.Observations["scan"])
+	}
+}
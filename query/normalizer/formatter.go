@@ -0,0 +1,360 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// Formatter renders an AST, raw or normalized, as indented multi-line SQL:
+// one clause per line, one line per join, and subqueries indented beneath
+// the clause they appear in.
+type Formatter struct {
+	// Indent is one level of indentation.  Defaults to two spaces.
+	Indent string
+	// Width is the line length past which select lists, column lists and
+	// the like are wrapped one item per line.  Defaults to 80.
+	Width int
+	// UppercaseKeywords renders SQL keywords in upper case.
+	UppercaseKeywords bool
+}
+
+// Format renders node as indented SQL.
+func (f *Formatter) Format(node sqlparser.SQLNode) string {
+	return f.node(node, 0)
+}
+
+// FormatQuery normalizes q with n and renders the result as indented SQL.
+// If q can't be parsed, the single line Scanner output is returned.
+func (f *Formatter) FormatQuery(n *Parser, q string) string {
+	ast, fallback := n.normalizeAST(q, true)
+	if ast == nil {
+		return fallback
+	}
+
+	// the tree has nowhere to put hints following the first keyword, and a
+	// REPLACE or INSERT IGNORE was parsed as a plain INSERT.
+	s := spliceHints(f.Format(ast), n.leadingHints)
+	if n.insertVariant != "" {
+		s = f.kw(n.insertVariant) + s[len("insert"):]
+	}
+	return s
+}
+
+func (f *Formatter) indent(depth int) string {
+	if f.Indent == "" {
+		return strings.Repeat("  ", depth)
+	}
+	return strings.Repeat(f.Indent, depth)
+}
+
+func (f *Formatter) width() int {
+	if f.Width <= 0 {
+		return 80
+	}
+	return f.Width
+}
+
+func (f *Formatter) kw(keyword string) string {
+	if f.UppercaseKeywords {
+		return strings.ToUpper(keyword)
+	}
+	return keyword
+}
+
+// node renders a statement or clause-level node whose first line starts at
+// depth.  The first line isn't indented, that's up to the caller.
+func (f *Formatter) node(node sqlparser.SQLNode, depth int) string {
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		return f.selectStatement(node, depth)
+	case *sqlparser.Union:
+		nl := "\n" + f.indent(depth)
+		return f.node(node.Left, depth) + nl + f.kw(strings.TrimSpace(node.Type)) + nl + f.node(node.Right, depth)
+	case *UnionRepeat:
+		nl := "\n" + f.indent(depth)
+		return f.node(node.Branch, depth) + nl + f.kw(strings.TrimSpace(node.Type)) + nl + "..."
+	case *sqlparser.Insert:
+		return f.insertStatement(node, depth)
+	case *sqlparser.Update:
+		return f.updateStatement(node, depth)
+	case *sqlparser.Delete:
+		return f.deleteStatement(node, depth)
+	case *sqlparser.Set:
+		return f.updateExprs(f.kw("set"), node.Exprs, depth)
+	case sqlparser.Values:
+		return f.values(node, depth)
+	case nil:
+		return ""
+	default:
+		return f.expr(node, depth)
+	}
+}
+
+func (f *Formatter) selectStatement(node *sqlparser.Select, depth int) string {
+	nl := "\n" + f.indent(depth)
+
+	// Distinct may also carry optimizer hints, keep those as is.
+	keyword := f.kw("select") + " " + strings.Replace(node.Distinct, sqlparser.AST_DISTINCT, f.kw(sqlparser.AST_DISTINCT), 1)
+	items := make([]string, 0, len(node.SelectExprs))
+	for _, se := range node.SelectExprs {
+		items = append(items, f.expr(se, depth+2))
+	}
+	s := f.clause(strings.TrimSpace(keyword), items, depth)
+
+	if len(node.From) > 0 {
+		s += nl + f.kw("from") + " " + f.tableExprs(node.From, depth)
+	}
+	s += f.where(node.Where, depth)
+	if len(node.GroupBy) > 0 {
+		items := make([]string, 0, len(node.GroupBy))
+		for _, e := range node.GroupBy {
+			items = append(items, f.expr(e, depth+2))
+		}
+		s += nl + f.clause(f.kw("group by"), items, depth)
+	}
+	s += f.where(node.Having, depth)
+	s += f.orderBy(node.OrderBy, depth)
+	s += f.limit(node.Limit, depth)
+	if node.Lock != "" {
+		s += nl + f.kw(strings.TrimSpace(node.Lock))
+	}
+	return s
+}
+
+func (f *Formatter) insertStatement(node *sqlparser.Insert, depth int) string {
+	nl := "\n" + f.indent(depth)
+
+	s := f.kw("insert into") + " " + f.expr(node.Table, depth)
+	if len(node.Columns) > 0 {
+		items := make([]string, 0, len(node.Columns))
+		for _, c := range node.Columns {
+			items = append(items, f.expr(c, depth+1))
+		}
+		s += " (" + strings.Join(items, ", ") + ")"
+	}
+	s += nl + f.node(node.Rows, depth)
+	if len(node.OnDup) > 0 {
+		s += nl + f.updateExprs(f.kw("on duplicate key update"), sqlparser.UpdateExprs(node.OnDup), depth)
+	}
+	return s
+}
+
+func (f *Formatter) updateStatement(node *sqlparser.Update, depth int) string {
+	nl := "\n" + f.indent(depth)
+
+	s := f.kw("update") + " " + f.expr(node.Table, depth)
+	s += nl + f.updateExprs(f.kw("set"), node.Exprs, depth)
+	s += f.where(node.Where, depth)
+	s += f.orderBy(node.OrderBy, depth)
+	s += f.limit(node.Limit, depth)
+	return s
+}
+
+func (f *Formatter) deleteStatement(node *sqlparser.Delete, depth int) string {
+	s := f.kw("delete from") + " " + f.expr(node.Table, depth)
+	s += f.where(node.Where, depth)
+	s += f.orderBy(node.OrderBy, depth)
+	s += f.limit(node.Limit, depth)
+	return s
+}
+
+// clause renders a clause keyword followed by a comma separated list of
+// items, on a single line if it fits within the formatter's width, otherwise
+// one item per line indented beneath the keyword.
+func (f *Formatter) clause(keyword string, items []string, depth int) string {
+	oneLine := strings.Join(items, ", ")
+	if len(f.indent(depth))+len(keyword)+1+len(oneLine) <= f.width() && !strings.Contains(oneLine, "\n") {
+		return keyword + " " + oneLine
+	}
+	nl := "\n" + f.indent(depth+1)
+	return keyword + nl + strings.Join(items, ","+nl)
+}
+
+func (f *Formatter) tableExprs(node sqlparser.TableExprs, depth int) string {
+	items := make([]string, 0, len(node))
+	for _, te := range node {
+		items = append(items, f.tableExpr(te, depth))
+	}
+	return strings.Join(items, ",\n"+f.indent(depth+1))
+}
+
+func (f *Formatter) tableExpr(node sqlparser.TableExpr, depth int) string {
+	switch node := node.(type) {
+	case *sqlparser.JoinTableExpr:
+		s := f.tableExpr(node.LeftExpr, depth)
+		s += "\n" + f.indent(depth) + f.kw(strings.TrimSpace(node.Join)) + " " + f.tableExpr(node.RightExpr, depth)
+		if node.On != nil {
+			s += " " + f.kw("on") + " " + f.expr(node.On, depth+1)
+		}
+		return s
+	case *sqlparser.ParenTableExpr:
+		return "(" + f.tableExpr(node.Expr, depth) + ")"
+	case *sqlparser.AliasedTableExpr:
+		s := f.expr(node.Expr, depth+1)
+		if node.As != nil {
+			s += " " + f.kw("as") + " " + string(node.As)
+		}
+		if node.Hints != nil {
+			s += " " + f.kw(node.Hints.Type+" index") + " ("
+			for i, index := range node.Hints.Indexes {
+				if i > 0 {
+					s += ", "
+				}
+				s += string(index)
+			}
+			s += ")"
+		}
+		return s
+	default:
+		return f.expr(node, depth)
+	}
+}
+
+// where renders a WHERE or HAVING clause with each top-level AND term on a
+// line of its own.
+func (f *Formatter) where(node *sqlparser.Where, depth int) string {
+	if node == nil {
+		return ""
+	}
+	var terms []sqlparser.BoolExpr
+	var flatten func(expr sqlparser.BoolExpr)
+	flatten = func(expr sqlparser.BoolExpr) {
+		if and, ok := expr.(*sqlparser.AndExpr); ok {
+			flatten(and.Left)
+			flatten(and.Right)
+			return
+		}
+		terms = append(terms, expr)
+	}
+	flatten(node.Expr)
+
+	nl := "\n" + f.indent(depth)
+	s := nl + f.kw(strings.TrimSpace(node.Type)) + " " + f.expr(terms[0], depth+1)
+	for _, term := range terms[1:] {
+		s += nl + f.indent(1) + f.kw("and") + " " + f.expr(term, depth+2)
+	}
+	return s
+}
+
+func (f *Formatter) orderBy(node sqlparser.OrderBy, depth int) string {
+	if len(node) == 0 {
+		return ""
+	}
+	items := make([]string, 0, len(node))
+	for _, order := range node {
+		item := f.expr(order.Expr, depth+2)
+		if order.Direction == sqlparser.AST_DESC {
+			item += " " + f.kw("desc")
+		}
+		items = append(items, item)
+	}
+	return "\n" + f.indent(depth) + f.clause(f.kw("order by"), items, depth)
+}
+
+func (f *Formatter) limit(node *sqlparser.Limit, depth int) string {
+	if node == nil {
+		return ""
+	}
+	s := "\n" + f.indent(depth) + f.kw("limit") + " "
+	if node.Offset != nil {
+		s += f.expr(node.Offset, depth) + ", "
+	}
+	return s + f.expr(node.Rowcount, depth)
+}
+
+func (f *Formatter) values(node sqlparser.Values, depth int) string {
+	items := make([]string, 0, len(node))
+	for _, row := range node {
+		items = append(items, f.expr(row, depth+1))
+	}
+	oneLine := strings.Join(items, ", ")
+	if len(f.indent(depth))+len("values ")+len(oneLine) <= f.width() {
+		return f.kw("values") + " " + oneLine
+	}
+	nl := "\n" + f.indent(depth+1)
+	return f.kw("values") + nl + strings.Join(items, ","+nl)
+}
+
+func (f *Formatter) updateExprs(keyword string, node sqlparser.UpdateExprs, depth int) string {
+	items := make([]string, 0, len(node))
+	for _, ue := range node {
+		items = append(items, f.expr(ue.Name, depth+2)+" = "+f.expr(ue.Expr, depth+2))
+	}
+	return f.clause(keyword, items, depth)
+}
+
+// expr renders an expression.  Expressions stay on one line unless they
+// contain a subquery, which is indented one level deeper than depth.
+func (f *Formatter) expr(node sqlparser.SQLNode, depth int) string {
+	switch node := node.(type) {
+	case nil:
+		return ""
+	case *sqlparser.Subquery:
+		// the closing parenthesis lines up with the clause the subquery is
+		// in, or the start of the line if the subquery is all there is.
+		closing := depth - 1
+		if closing < 0 {
+			closing = 0
+		}
+		return "(\n" + f.indent(depth) + f.node(node.Select, depth) + "\n" + f.indent(closing) + ")"
+	case *sqlparser.NonStarExpr:
+		s := f.expr(node.Expr, depth)
+		if len(node.As) > 0 {
+			s += " " + f.kw("as") + " " + string(node.As)
+		}
+		return s
+	case *sqlparser.AndExpr:
+		return f.expr(node.Left, depth) + " " + f.kw("and") + " " + f.expr(node.Right, depth)
+	case *sqlparser.OrExpr:
+		return f.expr(node.Left, depth) + " " + f.kw("or") + " " + f.expr(node.Right, depth)
+	case *sqlparser.NotExpr:
+		return f.kw("not") + " " + f.expr(node.Expr, depth)
+	case *sqlparser.ParenBoolExpr:
+		return "(" + f.expr(node.Expr, depth) + ")"
+	case *sqlparser.ComparisonExpr:
+		return f.expr(node.Left, depth) + " " + f.kw(node.Operator) + " " + f.expr(node.Right, depth)
+	case *sqlparser.RangeCond:
+		return f.expr(node.Left, depth) + " " + f.kw(node.Operator) + " " + f.expr(node.From, depth) + " " + f.kw("and") + " " + f.expr(node.To, depth)
+	case *sqlparser.ExistsExpr:
+		return f.kw("exists") + " " + f.expr(node.Subquery, depth)
+	case *sqlparser.NullVal:
+		return f.kw("null")
+	case sqlparser.ValTuple:
+		items := make([]string, 0, len(node))
+		for _, val := range node {
+			items = append(items, f.expr(val, depth))
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	case *sqlparser.BinaryExpr:
+		return f.expr(node.Left, depth) + " " + f.kw(node.Operator) + " " + f.expr(node.Right, depth)
+	case *sqlparser.UnaryExpr:
+		return string(node.Operator) + f.expr(node.Expr, depth)
+	case *sqlparser.FuncExpr:
+		s := string(node.Name) + "("
+		if node.Distinct {
+			s += f.kw("distinct") + " "
+		}
+		for i, se := range node.Exprs {
+			if i > 0 {
+				s += ", "
+			}
+			s += f.expr(se, depth)
+		}
+		return s + ")"
+	case *sqlparser.CaseExpr:
+		s := f.kw("case") + " "
+		if node.Expr != nil {
+			s += f.expr(node.Expr, depth) + " "
+		}
+		for _, when := range node.Whens {
+			s += f.kw("when") + " " + f.expr(when.Cond, depth) + " " + f.kw("then") + " " + f.expr(when.Val, depth) + " "
+		}
+		if node.Else != nil {
+			s += f.kw("else") + " " + f.expr(node.Else, depth) + " "
+		}
+		return s + f.kw("end")
+	default:
+		return string(sqlparser.Serialize(node, 0))
+	}
+}
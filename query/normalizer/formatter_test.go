@@ -0,0 +1,134 @@
+package normalizer_test
+
+import (
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/sqlparser"
+)
+
+var formatterTests = []struct {
+	ID       string
+	Input    string
+	Expected string
+}{
+	{"simple select",
+		"SELECT colname FROM tablename WHERE id = 5",
+		"select colname\nfrom tablename\nwhere id = ?",
+	},
+	{"joins and AND terms",
+		"SELECT a.id FROM a INNER JOIN b ON a.id = b.a_id LEFT JOIN c ON b.id = c.b_id WHERE a.x = 1 AND b.y = 2 ORDER BY a.id DESC LIMIT 10",
+		"select a.id\nfrom a\ninner join b on a.id = b.a_id\nleft join c on b.id = c.b_id\nwhere a.x = ?\n  and b.y = ?\norder by a.id desc\nlimit ?",
+	},
+	{"wrapped select list",
+		"SELECT first_column, second_column, third_column, fourth_column, fifth_column FROM t",
+		"select\n  first_column,\n  second_column,\n  third_column,\n  fourth_column,\n  fifth_column\nfrom t",
+	},
+	{"subqueries",
+		"SELECT x FROM t WHERE id IN (SELECT t_id FROM u WHERE u.y = 3) AND EXISTS (SELECT 1 FROM v WHERE v.t_id = t.id)",
+		"select x\nfrom t\nwhere id in (\n  select t_id\n  from u\n  where u.y = ?\n)\n  and exists (\n    select ?\n    from v\n    where v.t_id = t.id\n  )",
+	},
+	{"derived table",
+		"SELECT d.x FROM (SELECT x FROM t) AS d",
+		"select d.x\nfrom (\n  select x\n  from t\n) as d",
+	},
+	{"union",
+		"SELECT a FROM t UNION ALL SELECT b FROM u",
+		"select a\nfrom t\nunion all\nselect b\nfrom u",
+	},
+	{"insert",
+		"INSERT INTO t (a, b) VALUES (1, 2), (3, 4)",
+		"insert into t (a, b)\nvalues (?, ?), (?, ?)",
+	},
+	{"replace",
+		"REPLACE INTO t (a) VALUES (1)",
+		"replace into t (a)\nvalues (?)",
+	},
+	{"insert ignore",
+		"INSERT IGNORE INTO t (a) VALUES (1)",
+		"insert ignore into t (a)\nvalues (?)",
+	},
+	{"insert hints",
+		"INSERT /*+ SET_VAR(foreign_key_checks=OFF) */ INTO t (a) VALUES (1)",
+		"insert /*+ set_var(foreign_key_checks=off) */ into t (a)\nvalues (?)",
+	},
+	{"update hints",
+		"UPDATE /*+ NO_MERGE(t) */ t SET a = 1 WHERE id = 3",
+		"update /*+ no_merge(t) */ t\nset a = ?\nwhere id = ?",
+	},
+	{"update",
+		"UPDATE t SET a = 1, b = 'x' WHERE id = 3",
+		"update t\nset a = ?, b = ?\nwhere id = ?",
+	},
+	{"delete",
+		"DELETE FROM t WHERE id = 3 LIMIT 1",
+		"delete from t\nwhere id = ?\nlimit ?",
+	},
+	{"unparseable",
+		"select * from blah(",
+		"select * from blah(",
+	},
+}
+
+func TestFormatter(t *testing.T) {
+	n := &normalizer.Parser{}
+	f := &normalizer.Formatter{Width: 60}
+
+	for _, test := range formatterTests {
+		actual := f.FormatQuery(n, test.Input)
+		if test.Expected != actual {
+			t.Error("test '" + test.ID + "' failed.  actual =\n" + actual)
+		}
+	}
+}
+
+func TestFormatterUppercaseKeywords(t *testing.T) {
+	n := &normalizer.Parser{}
+	f := &normalizer.Formatter{UppercaseKeywords: true, Indent: "    "}
+
+	expected := "SELECT DISTINCT a.id, count(*)\nFROM a\nLEFT JOIN b ON a.id = b.a_id\nWHERE a.id BETWEEN ? AND ?\n    AND b.y IS NOT NULL\nGROUP BY a.id"
+	actual := f.FormatQuery(n, "select distinct a.id, count(*) from a left join b on a.id = b.a_id where a.id between 1 and 5 and b.y is not null group by a.id")
+	if expected != actual {
+		t.Error("uppercase keywords failed.  actual =\n" + actual)
+	}
+
+	expected = "INSERT IGNORE INTO t (a)\nVALUES (?)"
+	if actual := f.FormatQuery(n, "insert ignore into t (a) values (1)"); expected != actual {
+		t.Error("uppercase insert ignore failed.  actual =\n" + actual)
+	}
+}
+
+func TestFormatterSubquery(t *testing.T) {
+	stmt, err := sqlparser.Parse("select a from t where id = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &normalizer.Formatter{}
+
+	expected := "(\nselect a\nfrom t\nwhere id = 1\n)"
+	if actual := f.Format(&sqlparser.Subquery{Select: stmt.(sqlparser.SelectStatement)}); expected != actual {
+		t.Error("bare subquery failed.  actual =\n" + actual)
+	}
+}
+
+func TestFormatterParsesOnce(t *testing.T) {
+	instrumentation := &recordingInstrumentation{}
+	n := &normalizer.Parser{Instrumentation: instrumentation}
+	f := &normalizer.Formatter{}
+
+	// an unparseable query is scanned, not handed to the parser again.
+	f.FormatQuery(n, "SELECT * FROM t WHERE")
+	expected := "[fallback select parse_error parse select scan select]"
+	if actual := instrumentation.take(); expected != actual {
+		t.Error("unparseable query failed.  actual = " + actual)
+	}
+	if n.LastFallback != normalizer.FallbackParseError {
+		t.Error("unexpected fallback reason " + string(n.LastFallback))
+	}
+
+	f.FormatQuery(n, "SELECT * FROM t WHERE id = 1")
+	expected = "[parse select parsed select transform select]"
+	if actual := instrumentation.take(); expected != actual {
+		t.Error("parsed query failed.  actual = " + actual)
+	}
+}
@@ -0,0 +1,142 @@
+package normalizer
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// nondeterministicFuncs are functions whose result can differ between
+// executions of the same statement, making them unsafe for statement-based
+// replication or uncacheable.
+var nondeterministicFuncs = map[string]bool{
+	"connection_id":     true,
+	"curdate":           true,
+	"current_date":      true,
+	"current_time":      true,
+	"current_timestamp": true,
+	"current_user":      true,
+	"curtime":           true,
+	"database":          true,
+	"found_rows":        true,
+	"get_lock":          true,
+	"is_free_lock":      true,
+	"is_used_lock":      true,
+	"last_insert_id":    true,
+	"load_file":         true,
+	"localtime":         true,
+	"localtimestamp":    true,
+	"master_pos_wait":   true,
+	"now":               true,
+	"rand":              true,
+	"release_lock":      true,
+	"row_count":         true,
+	"schema":            true,
+	"session_user":      true,
+	"sleep":             true,
+	"sysdate":           true,
+	"system_user":       true,
+	"unix_timestamp":    true,
+	"user":              true,
+	"utc_date":          true,
+	"utc_time":          true,
+	"utc_timestamp":     true,
+	"uuid":              true,
+	"uuid_short":        true,
+	"version":           true,
+}
+
+// niladicFuncs are functions that can be called without parentheses, which
+// sqlparser parses as column names.
+var niladicFuncs = map[string]bool{
+	"current_date":      true,
+	"current_time":      true,
+	"current_timestamp": true,
+	"current_user":      true,
+	"localtime":         true,
+	"localtimestamp":    true,
+}
+
+// IsNondeterministicFunc returns true if the function named name can return
+// different results for the same arguments.
+func IsNondeterministicFunc(name string) bool {
+	return nondeterministicFuncs[strings.ToLower(name)]
+}
+
+func (n *Parser) addFunction(name string) {
+	name = strings.ToLower(name)
+	n.LastFunctions = appendUnique(n.LastFunctions, name)
+	if nondeterministicFuncs[name] {
+		n.LastNondeterministic = appendUnique(n.LastNondeterministic, name)
+	}
+}
+
+// addColumnFunction records col if it's really a niladic function call or
+// a user variable.
+func (n *Parser) addColumnFunction(col *sqlparser.ColName) {
+	if col.Qualifier != nil {
+		return
+	}
+	name := strings.ToLower(string(col.Name))
+	if niladicFuncs[name] {
+		lowerRunes(col.Name)
+		n.addFunction(name)
+	} else if strings.HasPrefix(name, "@") && !strings.HasPrefix(name, "@@") {
+		// user variables are session state, so they're as unsafe as
+		// any nondeterministic function.
+		n.LastNondeterministic = appendUnique(n.LastNondeterministic, name)
+	}
+}
+
+// addExprFunctions records the functions used in expr without transforming
+// it, for the clauses (ORDER BY, GROUP BY, HAVING) that aren't normalized.
+func (n *Parser) addExprFunctions(expr sqlparser.Expr) {
+	switch expr := expr.(type) {
+	case *sqlparser.FuncExpr:
+		lowerRunes(expr.Name)
+		n.addFunction(string(expr.Name))
+		for _, se := range expr.Exprs {
+			if nse, ok := se.(*sqlparser.NonStarExpr); ok {
+				n.addExprFunctions(nse.Expr)
+			}
+		}
+	case *sqlparser.ColName:
+		n.addColumnFunction(expr)
+	case *sqlparser.BinaryExpr:
+		n.addExprFunctions(expr.Left)
+		n.addExprFunctions(expr.Right)
+	case *sqlparser.UnaryExpr:
+		n.addExprFunctions(expr.Expr)
+	case *sqlparser.AndExpr:
+		n.addExprFunctions(expr.Left)
+		n.addExprFunctions(expr.Right)
+	case *sqlparser.OrExpr:
+		n.addExprFunctions(expr.Left)
+		n.addExprFunctions(expr.Right)
+	case *sqlparser.NotExpr:
+		n.addExprFunctions(expr.Expr)
+	case *sqlparser.ParenBoolExpr:
+		n.addExprFunctions(expr.Expr)
+	case *sqlparser.ComparisonExpr:
+		n.addExprFunctions(expr.Left)
+		n.addExprFunctions(expr.Right)
+	}
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// lowerRunes lowercases a function name in place.  Names are normally
+// lowercase already, unless identifier case is being preserved.
+func lowerRunes(name []rune) {
+	for i, r := range name {
+		name[i] = unicode.ToLower(r)
+	}
+}
@@ -0,0 +1,60 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var functionTests = []struct {
+	ID                       string
+	Input                    string
+	ExpectedFunctions        []string
+	ExpectedNondeterministic []string
+}{
+	{"deterministic functions",
+		"SELECT COUNT(*), MAX(colname) FROM tablename WHERE LOWER(name) = 'x'",
+		[]string{"count", "lower", "max"},
+		[]string{},
+	},
+	{"nondeterministic functions",
+		"INSERT INTO tablename (id, created) VALUES (UUID(), NOW())",
+		[]string{"now", "uuid"},
+		[]string{"now", "uuid"},
+	},
+	{"nested and niladic functions",
+		"SELECT colname FROM tablename WHERE created < DATE_SUB(CURRENT_TIMESTAMP, 5) ORDER BY RAND()",
+		[]string{"current_timestamp", "date_sub", "rand"},
+		[]string{"current_timestamp", "rand"},
+	},
+	{"update order by",
+		"UPDATE tablename SET created = NOW() WHERE id = 1 ORDER BY RAND() LIMIT 1",
+		[]string{"now", "rand"},
+		[]string{"now", "rand"},
+	},
+	{"delete where and order by",
+		"DELETE FROM tablename WHERE created < CURDATE() ORDER BY LENGTH(name) LIMIT 10",
+		[]string{"curdate", "length"},
+		[]string{"curdate"},
+	},
+	{"user variables",
+		"SELECT colname FROM tablename WHERE id = @last_id",
+		[]string{},
+		[]string{"@last_id"},
+	},
+}
+
+func TestParserFunctions(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range functionTests {
+		n.NormalizeQuery(test.Input)
+		if fmt.Sprint(test.ExpectedFunctions) != fmt.Sprint(n.LastFunctions) {
+			t.Error("test '" + test.ID + "' failed function accumulation.  actual = " + fmt.Sprint(n.LastFunctions))
+		}
+		if fmt.Sprint(test.ExpectedNondeterministic) != fmt.Sprint(n.LastNondeterministic) {
+			t.Error("test '" + test.ID + "' failed nondeterministic accumulation.  actual = " + fmt.Sprint(n.LastNondeterministic))
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package normalizer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FallbackReason is why a query was normalized by the Scanner rather than
+// being parsed.
+type FallbackReason string
+
+const (
+	// FallbackParseError means the backend couldn't parse the query.
+	FallbackParseError FallbackReason = "parse_error"
+	// FallbackTooLong means the query was longer than MaxQueryLength.
+	FallbackTooLong FallbackReason = "too_long"
+	// FallbackTooDeep means the query's parentheses nested deeper than
+	// MaxNestingDepth.
+	FallbackTooDeep FallbackReason = "too_deep"
+	// FallbackTimeout means parsing the query took longer than Timeout.
+	FallbackTimeout FallbackReason = "timeout"
+	// FallbackPanic means the backend panicked parsing the query.
+	FallbackPanic FallbackReason = "panic"
+)
+
+// guard runs parse, which parses and transforms q, within the Parser's
+// resource limits, recovering from any panic.  If q isn't parsed the reason
+// is returned, along with the error that caused it if there is one.
+func (n *Parser) guard(q string, parse func(p *Parser) error) (FallbackReason, error) {
+	if n.MaxQueryLength > 0 && len(q) > n.MaxQueryLength {
+		return FallbackTooLong, nil
+	}
+	if n.MaxNestingDepth > 0 && nestsDeeper(q, n.MaxNestingDepth) {
+		return FallbackTooDeep, nil
+	}
+	if n.Timeout <= 0 {
+		return runGuarded(n, parse)
+	}
+
+	// the parse can't be interrupted, so it's run on a copy of the Parser
+	// that's abandoned, left to finish in the background, if it takes too
+	// long.
+	p := n.detach()
+	done := make(chan struct{})
+	var reason FallbackReason
+	var err error
+	go func() {
+		reason, err = runGuarded(p, parse)
+		close(done)
+	}()
+
+	timer := time.NewTimer(n.Timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		*n = *p
+		return reason, err
+	case <-timer.C:
+		return FallbackTimeout, nil
+	}
+}
+
+// runGuarded runs parse on p, turning a panic into an error.
+func runGuarded(p *Parser, parse func(p *Parser) error) (reason FallbackReason, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			reason, err = FallbackPanic, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if err := parse(p); err != nil {
+		return FallbackParseError, err
+	}
+	return "", nil
+}
+
+// detach returns a copy of n that shares nothing n can see being changed:
+// the copy's lists can be appended to and its maps written without
+// affecting n's.
+func (n *Parser) detach() *Parser {
+	p := *n
+	r := &p.Result
+	r.LastTables = r.LastTables[:len(r.LastTables):len(r.LastTables)]
+	r.LastComments = r.LastComments[:len(r.LastComments):len(r.LastComments)]
+	r.LastINListSizes = r.LastINListSizes[:len(r.LastINListSizes):len(r.LastINListSizes)]
+	r.LastCommentTags = copyStringMap(r.LastCommentTags)
+	r.LastHints = r.LastHints[:len(r.LastHints):len(r.LastHints)]
+	r.LastMetrics.Joins = copyIntMap(r.LastMetrics.Joins)
+	r.LastJoins = r.LastJoins[:len(r.LastJoins):len(r.LastJoins)]
+	r.LastFunctions = r.LastFunctions[:len(r.LastFunctions):len(r.LastFunctions)]
+	r.LastNondeterministic = r.LastNondeterministic[:len(r.LastNondeterministic):len(r.LastNondeterministic)]
+	r.LastLimits = r.LastLimits[:len(r.LastLimits):len(r.LastLimits)]
+	r.LastBodyStatements = r.LastBodyStatements[:len(r.LastBodyStatements):len(r.LastBodyStatements)]
+	r.LastVariables = r.LastVariables[:len(r.LastVariables):len(r.LastVariables)]
+	r.LastOnDupColumns = r.LastOnDupColumns[:len(r.LastOnDupColumns):len(r.LastOnDupColumns)]
+
+	p.timings = n.timings[:len(n.timings):len(n.timings)]
+	p.rawCommentsUsed = append([]bool(nil), n.rawCommentsUsed...)
+	p.tableAliases = copyStringMap(n.tableAliases)
+	return &p
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyIntMap(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// nestsDeeper returns true if q's parentheses, outside quotes and comments,
+// nest more than max deep.
+func nestsDeeper(q string, max int) bool {
+	depth := 0
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(q) && q[i] != c; i++ {
+				if q[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+		case strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += 2 + end + 1
+		case strings.HasPrefix(q[i:], "-- ") || c == '#':
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				return false
+			}
+			i += end
+		case c == '(':
+			depth++
+			if depth > max {
+				return true
+			}
+		case c == ')':
+			depth--
+		}
+	}
+	return false
+}
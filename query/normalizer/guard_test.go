@@ -0,0 +1,143 @@
+package normalizer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// panicBackend panics on every query.
+type panicBackend struct{}
+
+func (panicBackend) Normalize(n *normalizer.Parser, q normalizer.BackendQuery) (string, error) {
+	panic("boom")
+}
+
+// slowBackend takes a while to parse every query.
+type slowBackend struct {
+	delay time.Duration
+}
+
+func (b slowBackend) Normalize(n *normalizer.Parser, q normalizer.BackendQuery) (string, error) {
+	time.Sleep(b.delay)
+	return normalizer.SQLParserBackend{}.Normalize(n, q)
+}
+
+var guardTests = []struct {
+	ID               string
+	Options          normalizer.Parser
+	Input            string
+	ExpectedOutput   string
+	ExpectedFallback normalizer.FallbackReason
+}{
+	{"within limits",
+		normalizer.Parser{MaxQueryLength: 100, MaxNestingDepth: 2, Timeout: time.Second},
+		"SELECT a FROM t WHERE id IN (1, 2)",
+		"select a from t where id in (...)",
+		"",
+	},
+	{"parse error",
+		normalizer.Parser{},
+		"SELECT * FROM t WHERE",
+		"select * from t where",
+		normalizer.FallbackParseError,
+	},
+	{"too long",
+		normalizer.Parser{MaxQueryLength: 20},
+		"SELECT a FROM t WHERE id IN (1, 2)",
+		"select a from t where id in (?, ?)",
+		normalizer.FallbackTooLong,
+	},
+	{"call too long",
+		normalizer.Parser{MaxQueryLength: 20},
+		"CALL proc(1, 2, 3, 4, 5, 6)",
+		"call proc(?, ?, ?, ?, ?, ?)",
+		normalizer.FallbackTooLong,
+	},
+	{"prepare too long",
+		normalizer.Parser{MaxQueryLength: 20},
+		"PREPARE stmt FROM 'SELECT a FROM t WHERE id = ?'",
+		"prepare stmt from ?",
+		normalizer.FallbackTooLong,
+	},
+	{"set too long",
+		normalizer.Parser{MaxQueryLength: 20},
+		"SET @a = 1, @b = 2, @c = 3",
+		"set @a = ?, @b = ?, @c = ?",
+		normalizer.FallbackTooLong,
+	},
+	{"create procedure too deep",
+		normalizer.Parser{MaxNestingDepth: 2},
+		"CREATE PROCEDURE p() BEGIN SELECT (((1))); END",
+		"create procedure p() begin select (((?))); end",
+		normalizer.FallbackTooDeep,
+	},
+	{"too deep",
+		normalizer.Parser{MaxNestingDepth: 3},
+		"SELECT a FROM t WHERE ((((id = 1))))",
+		"select a from t where ((((id = ?))))",
+		normalizer.FallbackTooDeep,
+	},
+	{"parentheses in strings and comments don't count",
+		normalizer.Parser{MaxNestingDepth: 1},
+		"SELECT /* ((( */ a FROM t WHERE b = '(((' AND c IN (1)",
+		"select a from t where b = ? and c in (...)",
+		"",
+	},
+	{"panic",
+		normalizer.Parser{Backend: panicBackend{}},
+		"SELECT a FROM t WHERE id = 1",
+		"select a from t where id = ?",
+		normalizer.FallbackPanic,
+	},
+	{"timeout",
+		normalizer.Parser{Backend: slowBackend{time.Second}, Timeout: 10 * time.Millisecond},
+		"SELECT a FROM t WHERE id = 1",
+		"select a from t where id = ?",
+		normalizer.FallbackTimeout,
+	},
+}
+
+func TestParserGuard(t *testing.T) {
+	for _, test := range guardTests {
+		n := test.Options
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedFallback != n.LastFallback {
+			t.Error("test '" + test.ID + "' failed fallback.  actual = " + string(n.LastFallback))
+		}
+	}
+}
+
+func TestParserGuardTimeoutResult(t *testing.T) {
+	// a parse that finishes in time fills in the Result as usual.
+	n := &normalizer.Parser{Backend: slowBackend{time.Millisecond}, Timeout: time.Second}
+	n.NormalizeQuery("SELECT /* app:web */ a FROM t JOIN u ON t.id = u.t_id")
+	if n.LastFallback != "" || strings.Join(n.LastTables, ",") != "t,u" || n.LastMetrics.Joins["join"] != 1 {
+		t.Errorf("unexpected result.  actual = %+v", n.Result)
+	}
+}
+
+func TestParserGuardAST(t *testing.T) {
+	n := &normalizer.Parser{MaxQueryLength: 10}
+	if ast := n.NormalizeAST("SELECT a FROM t WHERE id = 1"); ast != nil || n.LastFallback != normalizer.FallbackTooLong {
+		t.Error("expected NormalizeAST to refuse a long query.  actual = " + string(n.LastFallback))
+	}
+}
+
+func TestParserGuardLongQueryIsntInspected(t *testing.T) {
+	n := &normalizer.Parser{MaxQueryLength: 100}
+	q := "CALL proc(" + strings.Repeat("1, ", 50000) + "1) /* app:web */ /*+ NO_ICP(t) */"
+
+	n.NormalizeQuery(q)
+	if n.LastFallback != normalizer.FallbackTooLong || n.LastStatement != "" || len(n.LastTables) != 0 {
+		t.Errorf("long call was normalized.  actual = %+v", n.Result)
+	}
+	if len(n.LastCommentTags) != 0 || len(n.LastHints) != 0 {
+		t.Errorf("long query's comments were read.  actual = %+v", n.Result)
+	}
+}
@@ -0,0 +1,154 @@
+package normalizer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// OptimizerHint is a single MySQL optimizer hint from a /*+ ... */ comment,
+// e.g. INDEX(t idx) has Name "index" and Args ["t", "idx"].
+type OptimizerHint struct {
+	Name string
+	Args []string
+}
+
+// ParseOptimizerHints parses the body of an optimizer hint comment (the text
+// following "/*+").  Hint names are lowercased, arguments are kept as
+// written.
+func ParseOptimizerHints(body string) []OptimizerHint {
+	var hints []OptimizerHint
+
+	tokens := hintTokens(body)
+	for i := 0; i < len(tokens); i++ {
+		if !isHintWord(tokens[i]) {
+			continue
+		}
+		hint := OptimizerHint{Name: strings.ToLower(tokens[i])}
+
+		if i+1 < len(tokens) && tokens[i+1] == "(" {
+			i += 2
+			for depth := 1; i < len(tokens) && depth > 0; i++ {
+				switch tok := tokens[i]; tok {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				case ",":
+				default:
+					hint.Args = append(hint.Args, tok)
+				}
+			}
+			// the outer loop's increment steps past this.
+			i--
+		}
+		hints = append(hints, hint)
+	}
+
+	return hints
+}
+
+// normalizeHintComment renders the body of an optimizer hint comment in a
+// normalized form: whitespace collapsed and literal arguments (numbers and
+// quoted strings, including the value side of SET_VAR's name=value)
+// replaced with '?', e.g. "/*+ index(t idx) max_execution_time(?) */".
+// If preserveCase is set, the arguments, which name tables and indexes, keep
+// their case and only the hint names are lowercased.
+func normalizeHintComment(body string, preserveCase bool) string {
+	var b strings.Builder
+	b.WriteString("/*+")
+
+	prev := ""
+	depth := 0
+	for i, tok := range hintTokens(body) {
+		if i == 0 || (tok != "(" && tok != ")" && tok != "," && prev != "(") {
+			b.WriteByte(' ')
+		}
+		prev = tok
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ",":
+		default:
+			tok = normalizeHintArg(tok)
+		}
+		if depth == 0 || !preserveCase {
+			tok = strings.ToLower(tok)
+		}
+		b.WriteString(tok)
+	}
+
+	b.WriteString(" */")
+	return b.String()
+}
+
+func normalizeHintArg(arg string) string {
+	if eq := strings.IndexByte(arg, '='); eq >= 0 {
+		return arg[:eq+1] + normalizeHintArg(arg[eq+1:])
+	}
+	if arg == "" {
+		return arg
+	}
+	switch c := arg[0]; {
+	case c >= '0' && c <= '9', c == '\'', c == '"', c == '.', c == '-':
+		return "?"
+	}
+	return arg
+}
+
+// hintTokens splits a hint comment body into words, quoted strings, and the
+// punctuation "(", ")" and ",".  Whitespace is dropped.
+func hintTokens(body string) []string {
+	var tokens []string
+	var tok []rune
+	var quoteRune rune
+
+	flush := func() {
+		if len(tok) > 0 {
+			tokens = append(tokens, string(tok))
+			tok = tok[:0]
+		}
+	}
+
+	for _, r := range body {
+		if quoteRune != 0 {
+			tok = append(tok, r)
+			if r == quoteRune {
+				quoteRune = 0
+			}
+			continue
+		}
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			quoteRune = r
+			tok = append(tok, r)
+		case r == '(' || r == ')' || r == ',':
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			tok = append(tok, r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func isHintWord(tok string) bool {
+	for _, r := range tok {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return tok != ""
+}
+
+// isHintComment returns true if body is the body of an optimizer hint
+// comment, i.e. the comment started with "/*+".
+func isHintComment(body string) bool {
+	return strings.HasPrefix(body, "+")
+}
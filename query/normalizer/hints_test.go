@@ -0,0 +1,104 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var hintTests = []struct {
+	ID             string
+	Input          string
+	ExpectedOutput string
+	ExpectedHints  []normalizer.OptimizerHint
+}{
+	{"select hints",
+		"SELECT /*+ INDEX(t idx) MAX_EXECUTION_TIME(1000) */ colname FROM t WHERE id = 5",
+		"select /*+ index(t idx) max_execution_time(?) */ colname from t where id = ?",
+		[]normalizer.OptimizerHint{{Name: "index", Args: []string{"t", "idx"}}, {Name: "max_execution_time", Args: []string{"1000"}}},
+	},
+	{"update hints",
+		"UPDATE /*+ NO_RANGE_OPTIMIZER(t3 PRIMARY, f2_idx) */ t3 SET colname = 1 WHERE id = 2",
+		"update /*+ no_range_optimizer(t3 primary, f2_idx) */ t3 set colname = ? where id = ?",
+		[]normalizer.OptimizerHint{{Name: "no_range_optimizer", Args: []string{"t3", "PRIMARY", "f2_idx"}}},
+	},
+	{"subquery hints stay with the subquery",
+		"SELECT colname FROM t WHERE id IN (SELECT /*+ BKA(t2) */ id FROM t2)",
+		"select colname from t where id in (select /*+ bka(t2) */ id from t2)",
+		[]normalizer.OptimizerHint{{Name: "bka", Args: []string{"t2"}}},
+	},
+	{"hints and regular comments",
+		"SELECT /* regular comment */ /*+ NO_ICP(t) */ colname FROM t",
+		"select /*+ no_icp(t) */ colname from t",
+		[]normalizer.OptimizerHint{{Name: "no_icp", Args: []string{"t"}}},
+	},
+	{"select modifiers in executable comments",
+		"SELECT /*!40001 SQL_NO_CACHE */ a FROM t",
+		"select /*+ sql_no_cache */ a from t",
+		[]normalizer.OptimizerHint{{Name: "sql_no_cache"}},
+	},
+	{"select modifiers before star",
+		"SELECT /*!40001 SQL_NO_CACHE */ * FROM `t`",
+		"select /*+ sql_no_cache */ * from `t`",
+		[]normalizer.OptimizerHint{{Name: "sql_no_cache"}},
+	},
+	{"several select modifiers",
+		"SELECT /*! STRAIGHT_JOIN SQL_CALC_FOUND_ROWS */ a FROM t",
+		"select /*+ straight_join sql_calc_found_rows */ a from t",
+		[]normalizer.OptimizerHint{{Name: "straight_join"}, {Name: "sql_calc_found_rows"}},
+	},
+	{"executable comments are parsed as sql",
+		"/*!40101 SET @saved_cs_client = 5 */",
+		"set @saved_cs_client = ?",
+		[]normalizer.OptimizerHint{},
+	},
+}
+
+func TestParserOptimizerHints(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range hintTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if fmt.Sprint(test.ExpectedHints) != fmt.Sprint(n.LastHints) {
+			t.Error("test '" + test.ID + "' failed hint accumulation.  actual = " + fmt.Sprint(n.LastHints))
+		}
+	}
+
+	n.NormalizeQuery("SELECT /* regular comment */ /*+ NO_ICP(t) */ colname FROM t")
+	if fmt.Sprint(n.LastComments) != "[regular comment]" {
+		t.Error("hints were accumulated as comments.  actual = " + fmt.Sprint(n.LastComments))
+	}
+}
+
+func TestScannerExecutableComments(t *testing.T) {
+	n := &normalizer.Scanner{}
+
+	actual := n.NormalizeQuery("SELECT /*!40001 SQL_NO_CACHE */ * FROM `tablename` WHERE id = 5")
+	if actual != "select sql_no_cache * from `tablename` where id = ?" {
+		t.Error("executable comment failed normalization.  actual = " + actual)
+	}
+}
+
+func TestUnterminatedExecutableComments(t *testing.T) {
+	q := "SELECT * FROM t WHERE id = 5 " + strings.Repeat("/*! ", 75000)
+
+	// expanding the comments used to take time quadratic in their number.
+	start := time.Now()
+	(&normalizer.Parser{}).NormalizeQuery(q)
+	(&normalizer.Scanner{}).NormalizeQuery(q)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("unterminated executable comments took %v to normalize", elapsed)
+	}
+
+	n := &normalizer.Scanner{}
+	actual := n.NormalizeQuery("SELECT /*!40001 SQL_NO_CACHE */ * FROM t /*! WHERE id = 5")
+	if actual != "select sql_no_cache * from t /*! where id = ?" {
+		t.Error("unterminated executable comment failed normalization.  actual = " + actual)
+	}
+}
@@ -0,0 +1,72 @@
+package normalizer
+
+import (
+	"time"
+)
+
+// Phase is a step in normalizing a query that's timed for Instrumentation.
+type Phase string
+
+const (
+	// PhaseParse is sqlparser parsing a query.
+	PhaseParse Phase = "parse"
+	// PhaseTransform is the Parser normalizing the parsed tree.
+	PhaseTransform Phase = "transform"
+	// PhaseSerialize is the normalized tree being serialized back to SQL.
+	PhaseSerialize Phase = "serialize"
+	// PhaseScan is the Scanner normalizing a query.
+	PhaseScan Phase = "scan"
+)
+
+// Instrumentation receives counters and timings from inside a Parser or
+// Scanner, by statement type.  Statements a Parser couldn't parse are
+// identified by their first word.  Its methods may be called from several
+// goroutines at once.  The expvarmetrics package publishes them with
+// expvar.
+type Instrumentation interface {
+	// Parsed counts a query the Parser's backend normalized.  cached is
+	// true if the query's normalized form came from the Parser's Cache.
+	Parsed(statement string, cached bool)
+	// FellBack counts a query the Parser normalized with the Scanner
+	// instead, and why.  cached is true if the query's normalized form came
+	// from the Parser's Cache.
+	FellBack(statement string, reason FallbackReason, cached bool)
+	// Observe records how long a phase of normalizing a query took.
+	// Backends other than SQLParserBackend report no phases.
+	Observe(phase Phase, statement string, d time.Duration)
+}
+
+// phaseTiming is how long a phase of normalizing the current query took.
+type phaseTiming struct {
+	phase Phase
+	d     time.Duration
+}
+
+// observe records the time since start as the duration of phase, if the
+// Parser is instrumented.  The timings are reported once the query's
+// statement type is known.
+func (n *Parser) observe(phase Phase, start time.Time) {
+	if n.Instrumentation != nil {
+		n.timings = append(n.timings, phaseTiming{phase, time.Since(start)})
+	}
+}
+
+// instrument reports the normalization of q to the Parser's
+// Instrumentation, along with the time spent in the phases that ran, even
+// if the query wasn't parsed in the end.
+func (n *Parser) instrument(q string, reason FallbackReason, cached bool) {
+	if n.Instrumentation == nil {
+		return
+	}
+
+	statement := n.LastStatement
+	if reason != "" {
+		statement = firstWord(q)
+		n.Instrumentation.FellBack(statement, reason, cached)
+	} else {
+		n.Instrumentation.Parsed(statement, cached)
+	}
+	for _, t := range n.timings {
+		n.Instrumentation.Observe(t.phase, statement, t.d)
+	}
+}
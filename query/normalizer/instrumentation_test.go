@@ -0,0 +1,134 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// recordingInstrumentation records what it's told, ignoring durations.
+type recordingInstrumentation struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingInstrumentation) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingInstrumentation) Parsed(statement string, cached bool) {
+	r.record("parsed " + statement + cachedSuffix(cached))
+}
+
+func (r *recordingInstrumentation) FellBack(statement string, reason normalizer.FallbackReason, cached bool) {
+	r.record("fallback " + statement + " " + string(reason) + cachedSuffix(cached))
+}
+
+func cachedSuffix(cached bool) string {
+	if cached {
+		return " cached"
+	}
+	return ""
+}
+
+func (r *recordingInstrumentation) Observe(phase normalizer.Phase, statement string, d time.Duration) {
+	r.record(string(phase) + " " + statement)
+}
+
+func (r *recordingInstrumentation) take() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	sort.Strings(events)
+	return fmt.Sprint(events)
+}
+
+var instrumentationTests = []struct {
+	ID       string
+	Options  normalizer.Parser
+	Input    string
+	Expected string
+}{
+	{"parsed",
+		normalizer.Parser{},
+		"SELECT * FROM t WHERE id = 1",
+		"[parse select parsed select serialize select transform select]",
+	},
+	{"parsed in time",
+		normalizer.Parser{Timeout: time.Second},
+		"DELETE FROM t WHERE id = 1",
+		"[parse delete parsed delete serialize delete transform delete]",
+	},
+	{"parse error",
+		normalizer.Parser{},
+		"SELECT * FROM t WHERE",
+		"[fallback select parse_error parse select scan select]",
+	},
+	{"too long",
+		normalizer.Parser{MaxQueryLength: 10},
+		"UPDATE t SET a = 1",
+		"[fallback update too_long scan update]",
+	},
+	{"prepare",
+		normalizer.Parser{},
+		"PREPARE stmt FROM 'SELECT a FROM t WHERE id = ?'",
+		"[parsed prepare]",
+	},
+	{"execute",
+		normalizer.Parser{},
+		"EXECUTE stmt USING @id",
+		"[parsed execute]",
+	},
+	{"call",
+		normalizer.Parser{},
+		"CALL proc(1)",
+		"[parsed call]",
+	},
+	{"other backend",
+		normalizer.Parser{Backend: slowBackend{}},
+		"SELECT 1",
+		"[parse select parsed select serialize select transform select]",
+	},
+}
+
+func TestParserInstrumentation(t *testing.T) {
+	r := &recordingInstrumentation{}
+	for _, test := range instrumentationTests {
+		n := test.Options
+		n.Instrumentation = r
+		n.NormalizeQuery(test.Input)
+		if actual := r.take(); test.Expected != actual {
+			t.Error("test '" + test.ID + "' failed.  actual = " + actual)
+		}
+	}
+}
+
+func TestParserInstrumentationCached(t *testing.T) {
+	r := &recordingInstrumentation{}
+	n := &normalizer.Parser{Instrumentation: r, Cache: normalizer.NewCache(10), MaxQueryLength: 30}
+
+	for i := 0; i < 2; i++ {
+		n.NormalizeQuery("SELECT * FROM t WHERE id = 1")
+		n.NormalizeQuery("UPDATE t SET a = 1 WHERE id = 2")
+	}
+	expected := "[fallback update too_long fallback update too_long cached parse select parsed select parsed select cached scan update serialize select transform select]"
+	if actual := r.take(); expected != actual {
+		t.Error("cached instrumentation failed.  actual = " + actual)
+	}
+}
+
+func TestScannerInstrumentation(t *testing.T) {
+	r := &recordingInstrumentation{}
+	s := &normalizer.Scanner{Instrumentation: r}
+	s.NormalizeQuery("INSERT INTO t VALUES (1)")
+	if actual := r.take(); actual != "[scan insert]" {
+		t.Error("scanner instrumentation failed.  actual = " + actual)
+	}
+}
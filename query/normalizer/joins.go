@@ -0,0 +1,138 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// JoinEdge is a single join between two tables.  Tables are reported by
+// name, with aliases resolved.
+type JoinEdge struct {
+	LeftTable  string
+	RightTable string
+	// Type is the join as written, e.g. "join", "left join".
+	Type string
+	// Columns are the column pairs compared in the join's ON condition,
+	// oriented so the left column belongs to the left side of the join.
+	Columns []JoinColumns
+}
+
+// JoinColumns is a pair of columns compared in a join's ON condition.
+// Qualified columns have their table alias resolved, e.g. "users.id".
+type JoinColumns struct {
+	Left, Right string
+}
+
+func (n *Parser) addTableAlias(node *sqlparser.AliasedTableExpr) {
+	if len(node.As) == 0 {
+		return
+	}
+	if tableName, ok := node.Expr.(*sqlparser.TableName); ok {
+		n.tableAliases[trimBackticks(string(node.As))] = trimBackticks(string(tableName.Name))
+	}
+}
+
+func (n *Parser) addJoinEdge(node *sqlparser.JoinTableExpr) {
+	left := tableRefs(node.LeftExpr)
+	right := tableRefs(node.RightExpr)
+
+	edge := JoinEdge{Type: strings.TrimSpace(node.Join)}
+	for _, pair := range joinColumnPairs(node.On, nil) {
+		l, r := pair[0], pair[1]
+		if containsString(right, columnQualifier(l)) && !containsString(right, columnQualifier(r)) {
+			l, r = r, l
+		}
+		if edge.LeftTable == "" && containsString(left, columnQualifier(l)) {
+			edge.LeftTable = n.resolveTable(columnQualifier(l))
+		}
+		edge.Columns = append(edge.Columns, JoinColumns{Left: n.joinColumnName(l), Right: n.joinColumnName(r)})
+	}
+
+	// without a qualified ON condition to go by, the table nearest the
+	// join is the best guess for its left side.
+	if edge.LeftTable == "" && len(left) > 0 {
+		edge.LeftTable = n.resolveTable(left[len(left)-1])
+	}
+	if len(right) > 0 {
+		edge.RightTable = n.resolveTable(right[0])
+	}
+
+	n.LastJoins = append(n.LastJoins, edge)
+}
+
+func (n *Parser) resolveTable(ref string) string {
+	if table, ok := n.tableAliases[ref]; ok {
+		return table
+	}
+	return ref
+}
+
+func (n *Parser) joinColumnName(col *sqlparser.ColName) string {
+	name := trimBackticks(string(col.Name))
+	if qualifier := columnQualifier(col); qualifier != "" {
+		return n.resolveTable(qualifier) + "." + name
+	}
+	return name
+}
+
+// tableRefs returns the names by which the tables in node can be
+// referenced: their alias if they have one, otherwise their name.
+func tableRefs(node sqlparser.TableExpr) []string {
+	switch node := node.(type) {
+	case *sqlparser.AliasedTableExpr:
+		if len(node.As) > 0 {
+			return []string{trimBackticks(string(node.As))}
+		}
+		if tableName, ok := node.Expr.(*sqlparser.TableName); ok {
+			return []string{trimBackticks(string(tableName.Name))}
+		}
+	case *sqlparser.ParenTableExpr:
+		return tableRefs(node.Expr)
+	case *sqlparser.JoinTableExpr:
+		return append(tableRefs(node.LeftExpr), tableRefs(node.RightExpr)...)
+	}
+	return nil
+}
+
+// joinColumnPairs appends the column pairs compared in expr to pairs.
+func joinColumnPairs(expr sqlparser.BoolExpr, pairs [][2]*sqlparser.ColName) [][2]*sqlparser.ColName {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		pairs = joinColumnPairs(expr.Left, pairs)
+		pairs = joinColumnPairs(expr.Right, pairs)
+	case *sqlparser.OrExpr:
+		pairs = joinColumnPairs(expr.Left, pairs)
+		pairs = joinColumnPairs(expr.Right, pairs)
+	case *sqlparser.ParenBoolExpr:
+		pairs = joinColumnPairs(expr.Expr, pairs)
+	case *sqlparser.ComparisonExpr:
+		left, lok := expr.Left.(*sqlparser.ColName)
+		right, rok := expr.Right.(*sqlparser.ColName)
+		if lok && rok {
+			pairs = append(pairs, [2]*sqlparser.ColName{left, right})
+		}
+	}
+	return pairs
+}
+
+func columnQualifier(col *sqlparser.ColName) string {
+	if col.Qualifier == nil {
+		return ""
+	}
+	quals := strings.Split(string(col.Qualifier), ".")
+	return trimBackticks(quals[len(quals)-1])
+}
+
+func trimBackticks(name string) string {
+	return strings.Trim(name, "`")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
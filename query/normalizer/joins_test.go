@@ -0,0 +1,49 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var joinTests = []struct {
+	ID            string
+	Input         string
+	ExpectedJoins []normalizer.JoinEdge
+}{
+	{"inner join",
+		"SELECT `colname` FROM `tablename` INNER JOIN `tablename2` ON `tablename`.`colName` = `tablename2`.`colName2` WHERE `tablename`.`intCol` = 314159",
+		[]normalizer.JoinEdge{
+			{LeftTable: "tablename", RightTable: "tablename2", Type: "inner join", Columns: []normalizer.JoinColumns{{Left: "tablename.colname", Right: "tablename2.colname2"}}},
+		},
+	},
+	{"aliases are resolved and columns oriented",
+		"SELECT u.name FROM users AS u LEFT JOIN orders AS o ON o.user_id = u.id AND o.state = 'open'",
+		[]normalizer.JoinEdge{
+			{LeftTable: "users", RightTable: "orders", Type: "left join", Columns: []normalizer.JoinColumns{{Left: "users.id", Right: "orders.user_id"}}},
+		},
+	},
+	{"chained joins",
+		"SELECT a.colname FROM a JOIN b ON a.id = b.a_id JOIN c ON b.id = c.b_id",
+		[]normalizer.JoinEdge{
+			{LeftTable: "a", RightTable: "b", Type: "join", Columns: []normalizer.JoinColumns{{Left: "a.id", Right: "b.a_id"}}},
+			{LeftTable: "b", RightTable: "c", Type: "join", Columns: []normalizer.JoinColumns{{Left: "b.id", Right: "c.b_id"}}},
+		},
+	},
+	{"no joins",
+		"SELECT colname FROM tablename WHERE id = 5",
+		[]normalizer.JoinEdge{},
+	},
+}
+
+func TestParserJoins(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range joinTests {
+		n.NormalizeQuery(test.Input)
+		if fmt.Sprintf("%+v", test.ExpectedJoins) != fmt.Sprintf("%+v", n.LastJoins) {
+			t.Error("test '" + test.ID + "' failed join accumulation.  actual = " + fmt.Sprintf("%+v", n.LastJoins))
+		}
+	}
+}
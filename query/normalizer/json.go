@@ -0,0 +1,437 @@
+package normalizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// JSONQuery is the JSON form of a normalized query, for tooling that isn't
+// written in Go.  It's produced by Parser.NormalizeJSON, and JSONToSQL turns
+// it back into the query NormalizeQuery returns.
+//
+//	{
+//	  "statement": "select",
+//	  "placeholders": 1,
+//	  "root": {
+//	    "type": "Select",
+//	    "children": [
+//	      {"type": "SelectExprs", "field": "SelectExprs", "children": [
+//	        {"type": "StarExpr"}
+//	      ]},
+//	      {"type": "TableExprs", "field": "From", "children": [...]},
+//	      {"type": "Where", "field": "Where", "attrs": {"Type": " where "}, "children": [
+//	        {"type": "ComparisonExpr", "field": "Expr", "attrs": {"Operator": "="}, "children": [
+//	          {"type": "ColName", "field": "Left", "attrs": {"Name": "id"}},
+//	          {"type": "QuestionMarkExpr", "field": "Right", "placeholder": 1}
+//	        ]}
+//	      ]}
+//	    ]
+//	  }
+//	}
+type JSONQuery struct {
+	// Statement is the query's statement type, as in LastStatement.
+	Statement string `json:"statement"`
+	// Hints are the optimizer hints that followed the query's first
+	// keyword, which sqlparser drops from the tree.
+	Hints string `json:"hints,omitempty"`
+	// Variant is "replace" or "insert ignore" when the root is an Insert
+	// standing in for one of them.
+	Variant string `json:"variant,omitempty"`
+	// Placeholders is how many QuestionMarkExpr nodes the tree has.
+	Placeholders int `json:"placeholders"`
+	// Root is the normalized statement.
+	Root *JSONNode `json:"root"`
+}
+
+// JSONNode is the JSON form of a node in a normalized tree.
+//
+// Type is the name of the node's Go type, without its package: one of the
+// sqlparser node types (e.g. "Select", "ComparisonExpr", "NumVal") or
+// QuestionMarkExpr, EllipsisExpr or UnionRepeat.  A node's scalar fields are
+// in Attrs, keyed by field name: strings and identifiers as JSON strings,
+// bools as true, counts as numbers, rune operators as one character strings
+// and lists of identifiers as arrays of strings.  Zero valued fields are left
+// out, except that identifiers which are empty but present (e.g. an empty
+// alias) are kept.  The nodes a node contains are its Children, in the order
+// they're serialized, each naming the field of its parent it fills in Field.
+// A field that holds a list of nodes, like CaseExpr's Whens, has one child
+// per node in the list.  Nodes that are themselves lists, like SelectExprs
+// or ValTuple, have children without a Field, and nodes that are literals,
+// like StrVal or NumVal, have their text in Value.  Comments and ColumnAtts
+// have their entries in Values.
+//
+// Placeholder is set on QuestionMarkExpr nodes to their position among the
+// query's placeholders, counting from 1 in the order they appear in the
+// normalized query.
+type JSONNode struct {
+	Type        string                 `json:"type"`
+	Field       string                 `json:"field,omitempty"`
+	Value       string                 `json:"value,omitempty"`
+	Values      []string               `json:"values,omitempty"`
+	Attrs       map[string]interface{} `json:"attrs,omitempty"`
+	Children    []*JSONNode            `json:"children,omitempty"`
+	Placeholder int                    `json:"placeholder,omitempty"`
+}
+
+// NormalizeJSON normalizes q like NormalizeAST, filling in the Parser's
+// Result, and returns the normalized tree as a JSONQuery marshaled to JSON.
+// An error is returned if q can't be parsed by sqlparser.
+func (n *Parser) NormalizeJSON(q string) ([]byte, error) {
+	ast := n.NormalizeAST(q)
+	if ast == nil {
+		return nil, errors.New("normalizer: query can't be parsed")
+	}
+
+	e := &jsonEncoder{}
+	root := e.encode(reflect.ValueOf(ast))
+	return json.Marshal(&JSONQuery{
+		Statement:    n.LastStatement,
+		Hints:        n.leadingHints,
+		Variant:      n.insertVariant,
+		Placeholders: e.placeholders,
+		Root:         root,
+	})
+}
+
+// NewJSONNode returns the JSON form of node, which can be any tree built of
+// sqlparser nodes and the normalizer's own.
+func NewJSONNode(node sqlparser.SQLNode) *JSONNode {
+	if node == nil {
+		return nil
+	}
+	return (&jsonEncoder{}).encode(reflect.ValueOf(node))
+}
+
+// Node rebuilds the tree j is the JSON form of.
+func (j *JSONNode) Node() (sqlparser.SQLNode, error) {
+	v, err := decodeJSONNode(j)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface().(sqlparser.SQLNode), nil
+}
+
+// JSONToSQL serializes a JSONQuery produced by NormalizeJSON back to SQL,
+// returning the same query NormalizeQuery would have.
+func JSONToSQL(data []byte) (string, error) {
+	var q JSONQuery
+	if err := json.Unmarshal(data, &q); err != nil {
+		return "", err
+	}
+	if q.Root == nil {
+		return "", errors.New("normalizer: JSON query has no root")
+	}
+
+	node, err := q.Root.Node()
+	if err != nil {
+		return "", err
+	}
+	normalized := spliceHints(string(sqlparser.Serialize(node, 0)), q.Hints)
+	return restoreInsertVariant(normalized, q.Variant), nil
+}
+
+var sqlNodeType = reflect.TypeOf((*sqlparser.SQLNode)(nil)).Elem()
+
+// jsonNodeTypes are the node types a JSONNode can have, by name.
+var jsonNodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []sqlparser.SQLNode{
+		&sqlparser.Select{}, &sqlparser.Union{}, &sqlparser.Insert{},
+		&sqlparser.Update{}, &sqlparser.Delete{}, &sqlparser.Set{},
+		&sqlparser.DDL{}, &sqlparser.CreateTable{}, &sqlparser.Other{},
+		sqlparser.ColumnDefinitions{}, &sqlparser.ColumnDefinition{},
+		sqlparser.ColumnAtts{}, sqlparser.Comments{},
+		sqlparser.SelectExprs{}, &sqlparser.StarExpr{},
+		&sqlparser.NonStarExpr{}, sqlparser.Columns{},
+		sqlparser.TableExprs{}, &sqlparser.AliasedTableExpr{},
+		&sqlparser.TableName{}, &sqlparser.ParenTableExpr{},
+		&sqlparser.JoinTableExpr{}, &sqlparser.IndexHints{},
+		&sqlparser.Where{}, &sqlparser.AndExpr{}, &sqlparser.OrExpr{},
+		&sqlparser.NotExpr{}, &sqlparser.ParenBoolExpr{},
+		&sqlparser.ComparisonExpr{}, &sqlparser.RangeCond{},
+		&sqlparser.ExistsExpr{}, sqlparser.TimestampVal{},
+		sqlparser.BinaryVal{}, sqlparser.StrVal{}, sqlparser.NumVal{},
+		sqlparser.ValArg{}, &sqlparser.NullVal{}, &sqlparser.ColName{},
+		sqlparser.ValTuple{}, sqlparser.ValExprs{}, &sqlparser.Subquery{},
+		sqlparser.ListArg{}, &sqlparser.BinaryExpr{}, &sqlparser.UnaryExpr{},
+		&sqlparser.FuncExpr{}, &sqlparser.CaseExpr{}, &sqlparser.When{},
+		sqlparser.GroupBy{}, sqlparser.OrderBy{}, &sqlparser.Order{},
+		&sqlparser.Limit{}, sqlparser.Values{}, sqlparser.UpdateExprs{},
+		&sqlparser.UpdateExpr{}, sqlparser.OnDup{},
+		&QuestionMarkExpr{}, &EllipsisExpr{}, &UnionRepeat{},
+	} {
+		t := reflect.TypeOf(node)
+		jsonNodeTypes[jsonTypeName(t)] = t
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+var runesType = reflect.TypeOf([]rune(nil))
+
+// isRunes returns true if t is []rune, or a type defined as one.
+func isRunes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Int32
+}
+
+// isRunesList returns true if t is a list of identifiers, [][]rune or
+// []string.
+func isRunesList(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && (isRunes(t.Elem()) || t.Elem().Kind() == reflect.String)
+}
+
+// isNodeList returns true if t is a list of nodes that isn't a node itself,
+// e.g. CaseExpr's []*When.
+func isNodeList(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && !t.Implements(sqlNodeType) && t.Elem().Implements(sqlNodeType)
+}
+
+// jsonEncoder builds the JSON form of a tree, numbering its placeholders.
+type jsonEncoder struct {
+	placeholders int
+}
+
+func (e *jsonEncoder) encode(v reflect.Value) *JSONNode {
+	node := &JSONNode{Type: jsonTypeName(v.Type())}
+	if _, ok := v.Interface().(*QuestionMarkExpr); ok {
+		e.placeholders++
+		node.Placeholder = e.placeholders
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" {
+				e.encodeField(node, f.Name, v.Field(i))
+			}
+		}
+	case isRunes(v.Type()):
+		node.Value = string(v.Convert(runesType).Interface().([]rune))
+	case isRunesList(v.Type()):
+		node.Values = runesList(v)
+	case v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if elem := v.Index(i); !isNil(elem) {
+				node.Children = append(node.Children, e.encode(concrete(elem)))
+			}
+		}
+	}
+	return node
+}
+
+func (e *jsonEncoder) encodeField(node *JSONNode, name string, v reflect.Value) {
+	t := v.Type()
+	switch {
+	case t.Implements(sqlNodeType):
+		if isNil(v) {
+			return
+		}
+		child := e.encode(concrete(v))
+		child.Field = name
+		node.Children = append(node.Children, child)
+	case isNodeList(t):
+		for i := 0; i < v.Len(); i++ {
+			if elem := v.Index(i); !isNil(elem) {
+				child := e.encode(concrete(elem))
+				child.Field = name
+				node.Children = append(node.Children, child)
+			}
+		}
+	case isRunes(t):
+		if !v.IsNil() {
+			node.setAttr(name, string(v.Convert(runesType).Interface().([]rune)))
+		}
+	case isRunesList(t):
+		if !v.IsNil() {
+			node.setAttr(name, runesList(v))
+		}
+	case t.Kind() == reflect.String:
+		if v.String() != "" {
+			node.setAttr(name, v.String())
+		}
+	case t.Kind() == reflect.Bool:
+		if v.Bool() {
+			node.setAttr(name, true)
+		}
+	case t.Kind() == reflect.Int32:
+		if v.Int() != 0 {
+			node.setAttr(name, string(rune(v.Int())))
+		}
+	case t.Kind() == reflect.Int:
+		if v.Int() != 0 {
+			node.setAttr(name, v.Int())
+		}
+	}
+}
+
+func (j *JSONNode) setAttr(name string, value interface{}) {
+	if j.Attrs == nil {
+		j.Attrs = make(map[string]interface{})
+	}
+	j.Attrs[name] = value
+}
+
+// isNil returns true if v is a nil pointer, interface or slice.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+// concrete returns the value held by v if it's an interface.
+func concrete(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface {
+		return v.Elem()
+	}
+	return v
+}
+
+func runesList(v reflect.Value) []string {
+	list := make([]string, v.Len())
+	for i := range list {
+		if elem := v.Index(i); elem.Kind() == reflect.String {
+			list[i] = elem.String()
+		} else {
+			list[i] = string(elem.Convert(runesType).Interface().([]rune))
+		}
+	}
+	return list
+}
+
+// decodeJSONNode rebuilds the node j is the JSON form of.
+func decodeJSONNode(j *JSONNode) (reflect.Value, error) {
+	t, ok := jsonNodeTypes[j.Type]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("normalizer: unknown JSON node type %q", j.Type)
+	}
+
+	var node, v reflect.Value
+	if t.Kind() == reflect.Ptr {
+		node = reflect.New(t.Elem())
+		v = node.Elem()
+	} else {
+		node = reflect.New(t).Elem()
+		v = node
+	}
+
+	switch {
+	case v.Kind() == reflect.Struct:
+		for name, value := range j.Attrs {
+			f := v.FieldByName(name)
+			if !f.IsValid() || !f.CanSet() {
+				return node, fmt.Errorf("normalizer: %s has no field %q", j.Type, name)
+			}
+			if err := setJSONAttr(f, value); err != nil {
+				return node, fmt.Errorf("normalizer: %s.%s: %v", j.Type, name, err)
+			}
+		}
+		for _, c := range j.Children {
+			f := v.FieldByName(c.Field)
+			if c.Field == "" || !f.IsValid() || !f.CanSet() {
+				return node, fmt.Errorf("normalizer: %s has no field %q", j.Type, c.Field)
+			}
+			child, err := decodeJSONNode(c)
+			if err != nil {
+				return node, err
+			}
+			switch {
+			case child.Type().AssignableTo(f.Type()):
+				f.Set(child)
+			case isNodeList(f.Type()) && child.Type().AssignableTo(f.Type().Elem()):
+				f.Set(reflect.Append(f, child))
+			default:
+				return node, fmt.Errorf("normalizer: %s can't be %s.%s", c.Type, j.Type, c.Field)
+			}
+		}
+	case isRunes(t):
+		v.Set(reflect.ValueOf([]rune(j.Value)).Convert(t))
+	case isRunesList(t):
+		setRunesList(v, j.Values)
+	case v.Kind() == reflect.Slice:
+		v.Set(reflect.MakeSlice(t, 0, len(j.Children)))
+		for _, c := range j.Children {
+			child, err := decodeJSONNode(c)
+			if err != nil {
+				return node, err
+			}
+			if !child.Type().AssignableTo(t.Elem()) {
+				return node, fmt.Errorf("normalizer: %s can't be in a %s", c.Type, j.Type)
+			}
+			v.Set(reflect.Append(v, child))
+		}
+	}
+	return node, nil
+}
+
+// setJSONAttr sets f to an attribute value as encoding/json decoded it.
+func setJSONAttr(f reflect.Value, value interface{}) error {
+	t := f.Type()
+	switch {
+	case isRunes(t):
+		if s, ok := value.(string); ok {
+			f.Set(reflect.ValueOf([]rune(s)).Convert(t))
+			return nil
+		}
+	case isRunesList(t):
+		if list, ok := value.([]interface{}); ok {
+			values := make([]string, len(list))
+			for i, elem := range list {
+				if values[i], ok = elem.(string); !ok {
+					return fmt.Errorf("expected a string, got %v", elem)
+				}
+			}
+			setRunesList(f, values)
+			return nil
+		}
+	case t.Kind() == reflect.String:
+		if s, ok := value.(string); ok {
+			f.SetString(s)
+			return nil
+		}
+	case t.Kind() == reflect.Bool:
+		if b, ok := value.(bool); ok {
+			f.SetBool(b)
+			return nil
+		}
+	case t.Kind() == reflect.Int32:
+		if s, ok := value.(string); ok && len([]rune(s)) == 1 {
+			f.SetInt(int64([]rune(s)[0]))
+			return nil
+		}
+	case t.Kind() == reflect.Int:
+		if num, ok := value.(float64); ok {
+			f.SetInt(int64(num))
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported field type %s", t)
+	}
+	return fmt.Errorf("unexpected value %v", value)
+}
+
+func setRunesList(v reflect.Value, values []string) {
+	list := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, s := range values {
+		if elem := list.Index(i); elem.Kind() == reflect.String {
+			elem.SetString(s)
+		} else {
+			elem.Set(reflect.ValueOf([]rune(s)).Convert(elem.Type()))
+		}
+	}
+	v.Set(list)
+}
@@ -0,0 +1,103 @@
+package normalizer_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var jsonRoundTripTests = []struct {
+	ID      string
+	Options normalizer.Parser
+	Input   string
+}{
+	{"simple select", normalizer.Parser{}, "SELECT * FROM t WHERE id = 5"},
+	{"joins", normalizer.Parser{}, "SELECT a.id, b.x AS bx FROM a INNER JOIN b ON a.id = b.a_id LEFT JOIN c USE INDEX (i) ON b.id = c.b_id WHERE a.x = 1 AND (b.y = 'z' OR NOT c.z IS NULL)"},
+	{"functions and case", normalizer.Parser{}, "SELECT count(DISTINCT a), -b, CASE WHEN a > 1 THEN 'x' ELSE 'y' END FROM t GROUP BY a HAVING count(*) > 2 ORDER BY a DESC LIMIT 10, 20"},
+	{"in list and subqueries", normalizer.Parser{}, "SELECT x FROM t WHERE id IN (1, 2, 3) AND y BETWEEN 1 AND 5 AND EXISTS (SELECT 1 FROM u WHERE u.t_id = t.id)"},
+	{"derived table", normalizer.Parser{}, "SELECT d.x FROM (SELECT x FROM t) AS d"},
+	{"union", normalizer.Parser{}, "SELECT a FROM t WHERE a = 1 UNION ALL SELECT b FROM u UNION SELECT c FROM v"},
+	{"collapsed union", normalizer.Parser{CollapseUnions: true}, "SELECT a FROM t WHERE id = 1 UNION ALL SELECT a FROM t WHERE id = 2"},
+	{"insert", normalizer.Parser{}, "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y') ON DUPLICATE KEY UPDATE a = VALUES(a) + 1"},
+	{"collapsed values", normalizer.Parser{CollapseValues: true, BucketINLists: true}, "INSERT INTO t (a) VALUES (1), (2), (3)"},
+	{"replace", normalizer.Parser{}, "REPLACE INTO t (a) VALUES (1)"},
+	{"update", normalizer.Parser{}, "UPDATE t SET a = 1, b = NULL WHERE id = 3 ORDER BY id LIMIT 1"},
+	{"delete", normalizer.Parser{}, "DELETE FROM t WHERE id = 3"},
+	{"comments and hints", normalizer.Parser{}, "SELECT /* app:web */ /*+ MAX_EXECUTION_TIME(1000) */ a FROM t WHERE id = 1"},
+	{"typed placeholders", normalizer.Parser{TypedPlaceholders: true}, "SELECT a FROM t WHERE b = 'x' AND c = 1 AND d IS NULL"},
+	{"bucketed limit", normalizer.Parser{LimitMode: normalizer.LimitBucket}, "SELECT a FROM t LIMIT 50"},
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, test := range jsonRoundTripTests {
+		n := test.Options
+		expected := n.NormalizeQuery(test.Input)
+
+		j := test.Options
+		data, err := j.NormalizeJSON(test.Input)
+		if err != nil {
+			t.Error("test '" + test.ID + "' failed.  error = " + err.Error())
+			continue
+		}
+		if j.LastStatement != n.LastStatement {
+			t.Error("test '" + test.ID + "' failed.  LastStatement = " + j.LastStatement)
+		}
+
+		actual, err := normalizer.JSONToSQL(data)
+		if err != nil {
+			t.Error("test '" + test.ID + "' failed.  error = " + err.Error() + "\n" + string(data))
+			continue
+		}
+		if expected != actual {
+			t.Error("test '" + test.ID + "' failed.  actual = " + actual + ", expected = " + expected)
+		}
+	}
+}
+
+func TestJSONPlaceholders(t *testing.T) {
+	n := &normalizer.Parser{}
+	data, err := n.NormalizeJSON("SELECT a FROM t WHERE b = 1 AND c IN (SELECT d FROM u WHERE e = 2) LIMIT 3, 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var q normalizer.JSONQuery
+	if err := json.Unmarshal(data, &q); err != nil {
+		t.Fatal(err)
+	}
+	if q.Statement != "select" || q.Placeholders != 4 {
+		t.Error("unexpected query.  actual = " + string(data))
+	}
+
+	// the placeholders are numbered in the order they're serialized: b, e,
+	// the offset and the row count.
+	var fields []string
+	var walk func(node *normalizer.JSONNode, parent string)
+	walk = func(node *normalizer.JSONNode, parent string) {
+		if node.Placeholder > 0 {
+			fields = append(fields, strconv.Itoa(node.Placeholder)+":"+parent+"."+node.Field)
+		}
+		for _, child := range node.Children {
+			walk(child, node.Type)
+		}
+	}
+	walk(q.Root, "")
+
+	expected := "[1:ComparisonExpr.Right 2:ComparisonExpr.Right 3:Limit.Offset 4:Limit.Rowcount]"
+	if actual := fmt.Sprint(fields); actual != expected {
+		t.Error("unexpected placeholders.  actual = " + actual)
+	}
+}
+
+func TestJSONUnparseable(t *testing.T) {
+	n := &normalizer.Parser{}
+	if _, err := n.NormalizeJSON("select * from blah("); err == nil {
+		t.Error("expected an error for an unparseable query")
+	}
+	if _, err := normalizer.JSONToSQL([]byte(`{"root": {"type": "Bogus"}}`)); err == nil {
+		t.Error("expected an error for an unknown node type")
+	}
+}
@@ -0,0 +1,162 @@
+package normalizer
+
+import (
+	"strings"
+)
+
+// keywords are the words folded to lowercase when identifier case is
+// preserved: every keyword sqlparser's tokenizer knows (it only recognizes
+// them in lowercase, and its table isn't exported), plus the MySQL reserved
+// words and niladic functions the Scanner is likely to see in statements
+// sqlparser can't parse.
+var keywords = map[string]bool{
+	// sqlparser
+	"all": true, "alter": true, "analyze": true, "and": true, "as": true,
+	"asc": true, "between": true, "binary": true, "by": true, "case": true,
+	"create": true, "cross": true, "default": true, "delete": true,
+	"desc": true, "describe": true, "distinct": true, "drop": true,
+	"duplicate": true, "else": true, "end": true, "except": true,
+	"exists": true, "explain": true, "for": true, "force": true, "from": true,
+	"group": true, "having": true, "if": true, "ignore": true, "in": true,
+	"index": true, "inner": true, "insert": true, "intersect": true,
+	"into": true, "is": true, "join": true, "key": true, "left": true,
+	"like": true, "limit": true, "lock": true, "minus": true, "natural": true,
+	"not": true, "null": true, "offset": true, "on": true, "or": true,
+	"order": true, "outer": true, "rename": true, "right": true,
+	"select": true, "set": true, "show": true, "straight_join": true,
+	"table": true, "then": true, "to": true, "union": true, "unique": true,
+	"update": true, "use": true, "using": true, "values": true, "view": true,
+	"when": true, "where": true, "engine": true, "bit": true, "tinyint": true,
+	"smallint": true, "mediumint": true, "int": true, "integer": true,
+	"bigint": true, "real": true, "double": true, "float": true,
+	"decimal": true, "numeric": true, "char": true, "varchar": true,
+	"text": true, "mediumtext": true, "charset": true, "date": true,
+	"time": true, "timestamp": true, "datetime": true, "year": true,
+	"unsigned": true, "zerofill": true, "primary": true,
+	"auto_increment": true,
+
+	// MySQL reserved words
+	"call": true, "collate": true, "delayed": true, "div": true,
+	"high_priority": true, "interval": true, "low_priority": true,
+	"mod": true, "procedure": true, "regexp": true, "release": true,
+	"replace": true, "rlike": true, "sql_calc_found_rows": true,
+	"trigger": true, "with": true, "xor": true,
+
+	// niladic functions
+	"current_date": true, "current_time": true, "current_timestamp": true,
+	"current_user": true, "localtime": true, "localtimestamp": true,
+}
+
+// maxKeywordLen is the length of the longest word in keywords.
+const maxKeywordLen = len("sql_calc_found_rows")
+
+// isKeyword returns true if word, in any case, is one of keywords.
+func isKeyword(word string) bool {
+	if len(word) > maxKeywordLen {
+		return false
+	}
+	var lower [maxKeywordLen]byte
+	for i := 0; i < len(word); i++ {
+		lower[i] = toLowerASCII(word[i])
+	}
+	return keywords[string(lower[:len(word)])]
+}
+
+// isKeywordRunes is isKeyword for a word the Scanner has accumulated.
+func isKeywordRunes(word []rune) bool {
+	if len(word) > maxKeywordLen {
+		return false
+	}
+	var lower [maxKeywordLen]byte
+	for i, r := range word {
+		if r >= 0x80 {
+			return false
+		}
+		lower[i] = toLowerASCII(byte(r))
+	}
+	return keywords[string(lower[:len(word)])]
+}
+
+func toLowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// isWordByte returns true if c can be part of an unquoted keyword or
+// identifier.  Bytes of multi-byte UTF-8 runes count, so identifiers
+// containing them aren't split.
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '@' || c == '$' || c >= 0x80
+}
+
+// foldKeywords lowercases the keywords in q, and the statement's first word,
+// leaving identifiers, quoted strings and comments as written.  q is returned
+// without being copied if there's nothing to fold.
+func foldKeywords(q string) string {
+	var b strings.Builder
+	var quote byte
+	var escaped bool
+
+	copied := 0
+	first := true
+	for i := 0; i < len(q); {
+		c := q[i]
+		if quote != 0 {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+			i++
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			i++
+		case strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += 2 + end + 2
+			}
+		case strings.HasPrefix(q[i:], "--"):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += end
+			}
+		case isWordByte(c):
+			start := i
+			for i < len(q) && isWordByte(q[i]) {
+				i++
+			}
+			word := q[start:i]
+			if (first || isKeyword(word)) && strings.ToLower(word) != word {
+				if copied == 0 {
+					b.Grow(len(q))
+				}
+				b.WriteString(q[copied:start])
+				b.WriteString(strings.ToLower(word))
+				copied = i
+			}
+			first = false
+		default:
+			i++
+		}
+	}
+
+	if copied == 0 {
+		return q
+	}
+	b.WriteString(q[copied:])
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package normalizer
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/honeycombio/sqlparser"
+)
+
+// LimitMode is how the parser normalizes LIMIT and OFFSET values.
+type LimitMode int
+
+const (
+	// LimitPlaceholder replaces LIMIT and OFFSET values with '?', like any
+	// other literal.
+	LimitPlaceholder LimitMode = iota
+	// LimitKeep leaves LIMIT and OFFSET values as written.
+	LimitKeep
+	// LimitBucket replaces LIMIT and OFFSET values with their order of
+	// magnitude, e.g. "limit ?[11-100]".
+	LimitBucket
+)
+
+// LimitValues are the row count and offset of a single LIMIT clause.
+// Values given as bind placeholders are -1, a missing offset is 0 and values
+// too large for an int64 are math.MaxInt64.
+type LimitValues struct {
+	Rowcount int64
+	Offset   int64
+}
+
+// addLimit records a LIMIT clause's values before they're normalized.
+func (n *Parser) addLimit(node *sqlparser.Limit) {
+	n.LastLimits = append(n.LastLimits, LimitValues{
+		Rowcount: limitValue(node.Rowcount),
+		Offset:   limitValue(node.Offset),
+	})
+}
+
+// normalizeLimitValue normalizes a LIMIT or OFFSET value according to the
+// parser's LimitMode.
+func (n *Parser) normalizeLimitValue(val sqlparser.ValExpr) sqlparser.ValExpr {
+	if _, ok := val.(sqlparser.NumVal); !ok || n.LimitMode == LimitPlaceholder {
+		v, _ := transform(val, n).(sqlparser.ValExpr)
+		return v
+	}
+	if n.LimitMode == LimitBucket {
+		return &QuestionMarkExpr{Type: "[" + limitBucket(limitValue(val)) + "]"}
+	}
+	return val
+}
+
+func limitValue(val sqlparser.ValExpr) int64 {
+	switch val := val.(type) {
+	case nil:
+		return 0
+	case sqlparser.NumVal:
+		v, err := strconv.ParseInt(string(val), 10, 64)
+		if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
+			return math.MaxInt64
+		} else if err != nil {
+			return -1
+		}
+		return v
+	default:
+		return -1
+	}
+}
+
+// limitBucket returns the order-of-magnitude bucket v falls into: "0", "1",
+// "2-10", "11-100", "101-1000" and so on.
+func limitBucket(v int64) string {
+	if v <= 1 {
+		return strconv.FormatInt(v, 10)
+	}
+	var lower, upper int64 = 2, 10
+	for v > upper && upper <= (1<<62)/10 {
+		lower, upper = upper+1, upper*10
+	}
+	if v > upper {
+		return strconv.FormatInt(lower, 10) + "+"
+	}
+	return strconv.FormatInt(lower, 10) + "-" + strconv.FormatInt(upper, 10)
+}
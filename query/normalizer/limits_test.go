@@ -0,0 +1,73 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var limitTests = []struct {
+	ID             string
+	Input          string
+	ExpectedKeep   string
+	ExpectedBucket string
+	ExpectedLimits []normalizer.LimitValues
+}{
+	{"no limit",
+		"SELECT colname FROM tablename WHERE id = 5",
+		"select colname from tablename where id = ?",
+		"select colname from tablename where id = ?",
+		[]normalizer.LimitValues{},
+	},
+	{"row count",
+		"SELECT colname FROM tablename LIMIT 1",
+		"select colname from tablename limit 1",
+		"select colname from tablename limit ?[1]",
+		[]normalizer.LimitValues{{Rowcount: 1}},
+	},
+	{"offset and row count",
+		"SELECT colname FROM tablename LIMIT 500000, 50",
+		"select colname from tablename limit 500000, 50",
+		"select colname from tablename limit ?[100001-1000000], ?[11-100]",
+		[]normalizer.LimitValues{{Rowcount: 50, Offset: 500000}},
+	},
+	{"bind placeholders",
+		"SELECT colname FROM tablename LIMIT ?, ?",
+		"select colname from tablename limit ?, ?",
+		"select colname from tablename limit ?, ?",
+		[]normalizer.LimitValues{{Rowcount: -1, Offset: -1}},
+	},
+	{"overflowing row count",
+		"SELECT colname FROM tablename LIMIT 99999999999999999999",
+		"select colname from tablename limit 99999999999999999999",
+		"select colname from tablename limit ?[100000000000000001+]",
+		[]normalizer.LimitValues{{Rowcount: math.MaxInt64}},
+	},
+	{"subquery and delete",
+		"DELETE FROM tablename WHERE id IN (SELECT id FROM other LIMIT 0) LIMIT 1000",
+		"delete from tablename where id in (select id from other limit 0) limit 1000",
+		"delete from tablename where id in (select id from other limit ?[0]) limit ?[101-1000]",
+		[]normalizer.LimitValues{{Rowcount: 0}, {Rowcount: 1000}},
+	},
+}
+
+func TestParserLimits(t *testing.T) {
+	def := &normalizer.Parser{}
+	keep := &normalizer.Parser{LimitMode: normalizer.LimitKeep}
+	bucket := &normalizer.Parser{LimitMode: normalizer.LimitBucket}
+
+	for _, test := range limitTests {
+		def.NormalizeQuery(test.Input)
+		if fmt.Sprintf("%+v", test.ExpectedLimits) != fmt.Sprintf("%+v", def.LastLimits) {
+			t.Error("test '" + test.ID + "' failed limits.  actual = " + fmt.Sprintf("%+v", def.LastLimits))
+		}
+		if actual := keep.NormalizeQuery(test.Input); test.ExpectedKeep != actual {
+			t.Error("test '" + test.ID + "' failed keep normalization.  actual = " + actual)
+		}
+		if actual := bucket.NormalizeQuery(test.Input); test.ExpectedBucket != actual {
+			t.Error("test '" + test.ID + "' failed bucket normalization.  actual = " + actual)
+		}
+	}
+}
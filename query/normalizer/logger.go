@@ -0,0 +1,23 @@
+package normalizer
+
+// Logger receives the diagnostics a Parser produces, like the reason a
+// query fell back to the Scanner.  Its method matches log/slog's, so a
+// *slog.Logger can be used as is; the logrusadapter and stdlogadapter
+// packages adapt logrus and standard library loggers.
+type Logger interface {
+	// Debug logs msg along with alternating keys and values.
+	Debug(msg string, keysAndValues ...interface{})
+}
+
+// NopLogger is a Logger that discards everything.  It's what Parsers log to
+// by default.
+type NopLogger struct{}
+
+func (NopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+
+func (n *Parser) logger() Logger {
+	if n.Logger == nil {
+		return NopLogger{}
+	}
+	return n.Logger
+}
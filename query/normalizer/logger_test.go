@@ -0,0 +1,50 @@
+package normalizer_test
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// a *slog.Logger is a Logger without an adapter.
+var _ normalizer.Logger = (*slog.Logger)(nil)
+
+func TestParserSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	n := &normalizer.Parser{Logger: l}
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+
+	actual := buf.String()
+	if !strings.Contains(actual, "falling back to scan") || !strings.Contains(actual, "reason=parse_error") {
+		t.Error("unexpected log output.  actual = " + actual)
+	}
+}
+
+func TestParserNopLogger(t *testing.T) {
+	// without a Logger nothing is logged, not even to the global loggers.
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	logrus.SetOutput(&buf)
+	logrus.SetLevel(logrus.DebugLevel)
+	defer func() {
+		logrus.SetOutput(os.Stderr)
+		logrus.SetLevel(logrus.InfoLevel)
+	}()
+
+	n := &normalizer.Parser{}
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+	n.NormalizeQuery("ALTER TABLE t ADD COLUMN a INT")
+	if buf.Len() > 0 {
+		t.Error("unexpected log output.  actual = " + buf.String())
+	}
+}
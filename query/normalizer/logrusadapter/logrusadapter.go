@@ -0,0 +1,36 @@
+// Package logrusadapter lets a normalizer.Parser log to logrus.
+package logrusadapter
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is a normalizer.Logger that logs to a logrus logger at debug
+// level, with the keys and values as fields.
+type Logger struct {
+	logger logrus.FieldLogger
+}
+
+// New returns a Logger that logs to l, or to logrus's standard logger if l
+// is nil.
+func New(l logrus.FieldLogger) *Logger {
+	if l == nil {
+		l = logrus.StandardLogger()
+	}
+	return &Logger{logger: l}
+}
+
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	fields := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+	l.logger.WithFields(fields).Debug(msg)
+}
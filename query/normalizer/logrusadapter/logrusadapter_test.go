@@ -0,0 +1,30 @@
+package logrusadapter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/mysqltools/query/normalizer/logrusadapter"
+)
+
+var _ normalizer.Logger = (*logrusadapter.Logger)(nil)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+	l.SetLevel(logrus.DebugLevel)
+	l.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	n := &normalizer.Parser{Logger: logrusadapter.New(l)}
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+
+	actual := buf.String()
+	if !strings.Contains(actual, "falling back to scan") || !strings.Contains(actual, "reason=parse_error") {
+		t.Error("unexpected log output.  actual = " + actual)
+	}
+}
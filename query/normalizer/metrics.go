@@ -0,0 +1,28 @@
+package normalizer
+
+// QueryMetrics describes the structure of a parsed query.
+type QueryMetrics struct {
+	// Tables is the number of table references, a self join counts twice.
+	Tables int
+	// Joins counts joins by type, e.g. "join", "left join".
+	Joins map[string]int
+	// SubqueryDepth is the deepest level of subquery nesting.
+	SubqueryDepth int
+	// UnionBranches is the number of SELECTs combined by UNIONs.
+	UnionBranches int
+	// Predicates is the number of comparison, BETWEEN and EXISTS
+	// conditions.
+	Predicates int
+	HasWhere   bool
+}
+
+// Complexity returns a composite complexity score for the query.  Joins and
+// nested subqueries are weighted more heavily than tables and predicates
+// since they tend to dominate query cost.
+func (m *QueryMetrics) Complexity() int {
+	joins := 0
+	for _, count := range m.Joins {
+		joins += count
+	}
+	return m.Tables + 2*joins + 3*m.SubqueryDepth + m.UnionBranches + m.Predicates
+}
@@ -0,0 +1,55 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var metricsTests = []struct {
+	ID                 string
+	Input              string
+	ExpectedMetrics    normalizer.QueryMetrics
+	ExpectedComplexity int
+}{
+	{"simple select",
+		"SELECT colname FROM tablename WHERE id = 5",
+		normalizer.QueryMetrics{Tables: 1, Joins: map[string]int{}, Predicates: 1, HasWhere: true},
+		2,
+	},
+	{"no where clause",
+		"SELECT colname FROM tablename",
+		normalizer.QueryMetrics{Tables: 1, Joins: map[string]int{}},
+		1,
+	},
+	{"joins",
+		"SELECT a.colname FROM a INNER JOIN b ON a.id = b.a_id LEFT JOIN c ON b.id = c.b_id WHERE a.id BETWEEN 1 AND 5",
+		normalizer.QueryMetrics{Tables: 3, Joins: map[string]int{"inner join": 1, "left join": 1}, Predicates: 3, HasWhere: true},
+		10,
+	},
+	{"nested subqueries",
+		"SELECT colname FROM t1 WHERE id IN (SELECT t1_id FROM t2 WHERE EXISTS (SELECT 1 FROM t3 WHERE t3.id = t2.t3_id))",
+		normalizer.QueryMetrics{Tables: 3, Joins: map[string]int{}, SubqueryDepth: 2, Predicates: 3, HasWhere: true},
+		12,
+	},
+	{"union branches",
+		"SELECT colname FROM t1 UNION SELECT colname FROM t2 UNION ALL SELECT colname FROM t3",
+		normalizer.QueryMetrics{Tables: 3, Joins: map[string]int{}, UnionBranches: 3},
+		6,
+	},
+}
+
+func TestParserMetrics(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range metricsTests {
+		n.NormalizeQuery(test.Input)
+		if fmt.Sprintf("%+v", test.ExpectedMetrics) != fmt.Sprintf("%+v", n.LastMetrics) {
+			t.Error("test '" + test.ID + "' failed metrics.  actual = " + fmt.Sprintf("%+v", n.LastMetrics))
+		}
+		if test.ExpectedComplexity != n.LastMetrics.Complexity() {
+			t.Error("test '" + test.ID + "' failed complexity.  actual = " + fmt.Sprint(n.LastMetrics.Complexity()))
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package normalizertest checks that a normalizer.Backend behaves like the
+// built-in SQLParserBackend.
+package normalizertest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// Case is a query and what normalizing it with the given options must
+// produce.
+type Case struct {
+	ID string
+	// Options is the Parser whose options the query is normalized with.
+	// Its Backend is replaced with the backend under test.
+	Options  normalizer.Parser
+	Input    string
+	Expected string
+	Result   normalizer.Result
+}
+
+// Cases are the queries TestBackend checks.
+var Cases = []Case{
+	{ID: "simple select",
+		Input:    "SELECT colname FROM tablename WHERE id = 5",
+		Expected: "select colname from tablename where id = ?",
+		Result: normalizer.Result{
+			LastStatement: "select",
+			LastTables:    []string{"tablename"},
+			LastMetrics:   normalizer.QueryMetrics{Tables: 1, Predicates: 1, HasWhere: true},
+		},
+	},
+	{ID: "joins, functions and limits",
+		Input:    "SELECT a.id, COUNT(*) FROM a INNER JOIN b ON a.id = b.a_id WHERE a.created < NOW() GROUP BY a.id LIMIT 10, 20",
+		Expected: "select a.id,count(*) from a inner join b on a.id = b.a_id where a.created < now() group by a.id limit ?, ?",
+		Result: normalizer.Result{
+			LastStatement:        "select",
+			LastTables:           []string{"a", "b"},
+			LastMetrics:          normalizer.QueryMetrics{Tables: 2, Joins: map[string]int{"inner join": 1}, Predicates: 2, HasWhere: true},
+			LastJoins:            []normalizer.JoinEdge{{LeftTable: "a", RightTable: "b", Type: "inner join", Columns: []normalizer.JoinColumns{{Left: "a.id", Right: "b.a_id"}}}},
+			LastFunctions:        []string{"count", "now"},
+			LastNondeterministic: []string{"now"},
+			LastLimits:           []normalizer.LimitValues{{Rowcount: 20, Offset: 10}},
+		},
+	},
+	{ID: "IN lists and subqueries",
+		Input:    "SELECT x FROM t1 WHERE id IN (1, 2, 3) AND y IN (SELECT y FROM t2)",
+		Expected: "select x from t1 where id in (...) and y in (select y from t2)",
+		Result: normalizer.Result{
+			LastStatement:   "select",
+			LastTables:      []string{"t1", "t2"},
+			LastINListSizes: []int{3},
+			LastMetrics:     normalizer.QueryMetrics{Tables: 2, SubqueryDepth: 1, Predicates: 2, HasWhere: true},
+		},
+	},
+	{ID: "comments, tags and hints",
+		Input:    "SELECT /*+ MAX_EXECUTION_TIME(1000) */ /* app:Web,action:show */ x FROM t",
+		Expected: "select /*+ max_execution_time(?) */ x from t",
+		Result: normalizer.Result{
+			LastStatement:   "select",
+			LastTables:      []string{"t"},
+			LastComments:    []string{"app:web,action:show"},
+			LastCommentTags: map[string]string{"app": "Web", "action": "show"},
+			LastHints:       []normalizer.OptimizerHint{{Name: "max_execution_time", Args: []string{"1000"}}},
+			LastMetrics:     normalizer.QueryMetrics{Tables: 1},
+		},
+	},
+	{ID: "insert",
+		Input:    "INSERT INTO t (a, b) VALUES (1, 'x'), (2, NULL)",
+		Expected: "insert into t(a,b) values (?, ?), (?, null)",
+		Result: normalizer.Result{
+			LastStatement: "insert",
+			LastTables:    []string{"t"},
+			LastRowCount:  2,
+			LastMetrics:   normalizer.QueryMetrics{Tables: 1},
+		},
+	},
+	{ID: "update",
+		Input:    "UPDATE t SET a = 1 WHERE id = 2 LIMIT 1",
+		Expected: "update t set a = ? where id = ? limit ?",
+		Result: normalizer.Result{
+			LastStatement: "update",
+			LastTables:    []string{"t"},
+			LastMetrics:   normalizer.QueryMetrics{Tables: 1, Predicates: 1, HasWhere: true},
+			LastLimits:    []normalizer.LimitValues{{Rowcount: 1}},
+		},
+	},
+	{ID: "options",
+		Options: normalizer.Parser{
+			BucketINLists:          true,
+			CollapseValues:         true,
+			TypedPlaceholders:      true,
+			PreserveIdentifierCase: true,
+			LimitMode:              normalizer.LimitBucket,
+		},
+		Input:    "SELECT Name FROM Users WHERE Id IN (1, 2) AND Name = 'x' LIMIT 50",
+		Expected: "select Name from Users where Id in (...1-10) and Name = ?s limit ?[11-100]",
+		Result: normalizer.Result{
+			LastStatement:   "select",
+			LastTables:      []string{"Users"},
+			LastINListSizes: []int{2},
+			LastMetrics:     normalizer.QueryMetrics{Tables: 1, Predicates: 2, HasWhere: true},
+			LastLimits:      []normalizer.LimitValues{{Rowcount: 50}},
+		},
+	},
+	{ID: "unparseable falls back to the scanner",
+		Input:    "SELECT * FROM t WHERE",
+		Expected: "select * from t where",
+		Result:   normalizer.Result{LastFallback: normalizer.FallbackParseError},
+	},
+}
+
+// TestBackend normalizes each of Cases with backend, failing t for every
+// difference from the expected query and result.
+func TestBackend(t *testing.T, backend normalizer.Backend) {
+	for _, test := range Cases {
+		n := test.Options
+		n.Backend = backend
+
+		actual := n.NormalizeQuery(test.Input)
+		if test.Expected != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+
+		if fmt.Sprintf("%+v", test.Result) != fmt.Sprintf("%+v", n.Result) {
+			t.Error("test '" + test.ID + "' failed result.  actual = " + fmt.Sprintf("%+v", n.Result))
+		}
+	}
+}
@@ -1,46 +1,339 @@
 package normalizer
 
 import (
-	"log"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"github.com/sirupsen/logrus"
 	"github.com/honeycombio/sqlparser"
 )
 
 type Parser struct {
-	LastStatement string
-	LastTables    []string
-	LastComments  []string
+	// BucketINLists keeps a coarse size bucket for collapsed IN lists in
+	// the fingerprint, e.g. "in (...1-10)" instead of "in (...)".
+	BucketINLists bool
+	// CollapseValues collapses multi-row INSERT VALUES lists to their first
+	// row followed by an ellipsis, e.g. "values (?, ?), (...)".
+	CollapseValues bool
+	// TypedPlaceholders tags the '?' literals are replaced with by the
+	// literal's type: ?s for strings, ?n for numbers, ?x for BINARY
+	// values, ?t for timestamps and ?null for NULL, so that e.g.
+	// "id = 5" and "id = '5'" no longer share a fingerprint.
+	TypedPlaceholders bool
+	// PreserveIdentifierCase lowercases only keywords and function names,
+	// leaving table and column names as written, for servers where table
+	// names are case sensitive (lower_case_table_names=0).
+	PreserveIdentifierCase bool
+	// LimitMode is how LIMIT and OFFSET values are normalized.  By default
+	// they're replaced with '?' like any other literal.
+	LimitMode LimitMode
+	// CollapseUnions collapses runs of identical UNION branches to a single
+	// branch followed by a repeat marker, e.g. "select ? union all ...".
+	// LastMetrics.UnionBranches still counts every branch.
+	CollapseUnions bool
+	// CanonicalPredicates rewrites logically identical conditions to one
+	// form: the terms of AND and OR chains are sorted, the sides of
+	// comparisons like "=" are put in order, NOT is folded into the
+	// comparison it negates ("not a is null" becomes "a is not null"),
+	// NOT IN lists are collapsed like IN lists and INNER JOIN and CROSS
+	// JOIN become JOIN.
+	CanonicalPredicates bool
+	// StripAliases renames table aliases to t1, t2, ... in the order they
+	// appear, and column aliases to c1, c2, ... by their position in the
+	// SELECT list, along with the references to them, so that queries
+	// differing only in alias names share a fingerprint.  Tables are still
+	// reported in LastTables and LastJoins by their original names.
+	StripAliases bool
+
+	// MaxQueryLength is the longest query, in bytes, that's parsed.  Longer
+	// queries are normalized by the Scanner.  Zero means no limit.
+	MaxQueryLength int
+	// MaxNestingDepth is how deeply a query's parentheses can nest and
+	// still be parsed.  Deeper queries are normalized by the Scanner.  Zero
+	// means no limit.
+	MaxNestingDepth int
+	// Timeout is how long parsing a query can take before the Parser gives
+	// up and normalizes it with the Scanner.  The parse can't be stopped,
+	// it finishes in the background.  Zero means no limit.
+	Timeout time.Duration
+
+	// Backend is the grammar queries are parsed with.  If nil, queries are
+	// parsed with SQLParserBackend.
+	Backend Backend
+	// Instrumentation receives counters and timings for the queries the
+	// Parser normalizes.  If nil, none are kept.
+	Instrumentation Instrumentation
+	// Cache, if set, remembers the queries the Parser normalizes, so
+	// that repeated queries aren't parsed again.
+	Cache *Cache
+	// Logger receives the Parser's diagnostics.  If nil, they're
+	// discarded.
+	Logger Logger
+	// Session remembers the statements PREPAREd through the Parser, to
+	// link EXECUTEs back to them.  Normalize each client connection's
+	// queries with its own Session.  If nil, a Session is created on first
+	// use.
+	Session *Session
+
+	// Result holds the Last* fields describing the last query normalized.
+	Result
+
+	// the body of every block comment in the query, used to tell optimizer
+	// hints apart from regular comments.  sqlparser drops the first rune
+	// of a comment, so the "+" of a hint is lost in the AST.
+	rawComments     []string
+	rawCommentsUsed []bool
+	// normalized optimizer hints following the statement's first keyword,
+	// for statements that have nowhere to put them in the AST.
+	leadingHints string
+	// how many subqueries deep the transform currently is.
+	subqueryDepth int
+	// table aliases seen so far, mapped to the table's name.
+	tableAliases map[string]string
+	// "replace" or "insert ignore" if the query was rewritten as a plain
+	// INSERT for sqlparser.
+	insertVariant string
+	// how long each phase of normalizing the query took, if the Parser is
+	// instrumented.
+	timings []phaseTiming
+}
+
+// Result holds what a Parser learned about the last query it normalized.
+type Result struct {
+	LastStatement   string
+	LastTables      []string
+	LastComments    []string
+	LastINListSizes []int
+	LastRowCount    int
+	// LastCommentTags holds the sqlcommenter/marginalia tags decoded from
+	// the query's comments.
+	LastCommentTags map[string]string
+	LastHints       []OptimizerHint
+	LastMetrics     QueryMetrics
+	LastJoins       []JoinEdge
+	LastFunctions   []string
+	// LastNondeterministic lists the nondeterministic functions and user
+	// variables the query uses.
+	LastNondeterministic []string
+	// LastLimits holds the values of every LIMIT clause in the query, as
+	// written, whatever the LimitMode.
+	LastLimits []LimitValues
+	// LastPrepared is the prepared statement a PREPARE, EXECUTE or
+	// DEALLOCATE PREPARE refers to.
+	LastPrepared PreparedStatement
+	// LastBodyStatements holds the fingerprints of the statements in the
+	// body of a CREATE PROCEDURE, FUNCTION or TRIGGER, in order.
+	LastBodyStatements []string
+	// LastVariables lists the variables a SET statement assigns, in order.
+	LastVariables []Variable
+	// LastOnDupColumns lists the columns an INSERT's ON DUPLICATE KEY
+	// UPDATE clause assigns.
+	LastOnDupColumns []string
+	// LastFallback is why the query wasn't parsed, if it wasn't.
+	LastFallback FallbackReason
+}
+
+// reset clears r for a new query.
+func (r *Result) reset() {
+	*r = Result{
+		LastTables:           make([]string, 0),
+		LastComments:         make([]string, 0),
+		LastINListSizes:      make([]int, 0),
+		LastCommentTags:      make(map[string]string),
+		LastHints:            make([]OptimizerHint, 0),
+		LastMetrics:          QueryMetrics{Joins: make(map[string]int)},
+		LastJoins:            make([]JoinEdge, 0),
+		LastFunctions:        make([]string, 0),
+		LastNondeterministic: make([]string, 0),
+		LastLimits:           make([]LimitValues, 0),
+		LastBodyStatements:   make([]string, 0),
+		LastVariables:        make([]Variable, 0),
+		LastOnDupColumns:     make([]string, 0),
+	}
 }
 
 func (n *Parser) NormalizeQuery(q string) string {
-	n.LastStatement = ""
-	n.LastTables = make([]string, 0)
-	n.LastComments = make([]string, 0)
+	if n.Cache == nil || !cacheable(q) {
+		return n.normalizeQuery(q)
+	}
 
+	if normalized, result, ok := n.Cache.get(q); ok {
+		n.Result = result
+		n.timings = nil
+		n.instrument(q, n.LastFallback, true)
+		return normalized
+	}
+	normalized := n.normalizeQuery(q)
+	if n.LastFallback != FallbackTimeout {
+		// whether a query times out depends on more than the query.
+		n.Cache.add(q, normalized, n.Result)
+	}
+	return normalized
+}
+
+func (n *Parser) normalizeQuery(q string) string {
+	q = n.prepare(q)
 	if q == "" {
 		return ""
 	}
 
-	q = strings.ToLower(q)
+	// parsed is written by the parse, which is left running if it times
+	// out, so it's only read once the parse is done.
+	var parsed string
+	reason, err := n.guard(q, func(p *Parser) (err error) {
+		parsed, err = p.parse(q)
+		return err
+	})
 
-	sqlAST, err := sqlparser.Parse(q)
-	if err != nil {
-		logrus.WithError(err).Debug("parse error, falling back to scan, query: ", q)
-		s := &Scanner{}
-		return s.NormalizeQuery(q)
+	var normalized string
+	if reason != "" {
+		normalized = n.fallBack(q, reason, err)
+	} else {
+		normalized = parsed
+		n.finish()
 	}
+	n.instrument(q, reason, false)
+	return normalized
+}
 
-	newAST := transform(sqlAST, n)
-	if newAST == nil {
-		return ""
+// fallBack records why q couldn't be parsed, and returns q normalized by the
+// Scanner instead.
+func (n *Parser) fallBack(q string, reason FallbackReason, err error) string {
+	n.logger().Debug("falling back to scan", "reason", reason, "error", err, "query", q)
+	n.LastFallback = reason
+	s := &Scanner{PreserveIdentifierCase: n.PreserveIdentifierCase, Instrumentation: n.Instrumentation}
+	return s.NormalizeQuery(q)
+}
+
+// parse normalizes q, with the Parser's Backend unless it's a statement
+// sqlparser doesn't understand, whatever the backend.
+func (n *Parser) parse(q string) (string, error) {
+	switch firstWord(q) {
+	case "prepare", "execute", "deallocate", "drop":
+		if normalized, ok := n.normalizePrepared(q); ok {
+			return normalized, nil
+		}
+	case "call", "create":
+		if normalized, ok := n.normalizeRoutine(q); ok {
+			return normalized, nil
+		}
+	}
+
+	backend := n.Backend
+	if backend == nil {
+		backend = SQLParserBackend{}
+	}
+	normalized, err := backend.Normalize(n, n.newBackendQuery(q))
+
+	// SET statements are described whether or not they could be parsed.
+	if firstWord(q) == "set" {
+		n.describeSet(q)
+	}
+	return normalized, err
+}
+
+// firstWord returns the first word of q, lowercased, skipping any leading
+// comments.
+func firstWord(q string) string {
+	for {
+		q = strings.TrimLeft(q, " \t\r\n(")
+		if !strings.HasPrefix(q, "/*") {
+			break
+		}
+		end := strings.Index(q, "*/")
+		if end < 0 {
+			return ""
+		}
+		q = q[end+2:]
 	}
 
-	n.LastStatement = classifyStatement(sqlAST)
+	end := 0
+	for end < len(q) && isWordByte(q[end]) {
+		end++
+	}
+	return strings.ToLower(q[:end])
+}
 
+// NormalizeAST parses and normalizes q like NormalizeQuery, but returns the
+// normalized AST rather than serializing it.  q is always parsed with
+// sqlparser, whatever the Parser's Backend.  nil is returned if q can't be
+// parsed, or breaks one of the Parser's limits, with the reason in
+// LastFallback.
+func (n *Parser) NormalizeAST(q string) sqlparser.SQLNode {
+	newAST, _ := n.normalizeAST(q, false)
+	return newAST
+}
+
+// normalizeAST is NormalizeAST.  If scan is set and q can't be parsed, q
+// normalized by the Scanner is returned as well, so that callers wanting
+// text either way don't parse q a second time.
+func (n *Parser) normalizeAST(q string, scan bool) (sqlparser.SQLNode, string) {
+	q = n.prepare(q)
+	if q == "" {
+		return nil, ""
+	}
+
+	var newAST sqlparser.SQLNode
+	reason, err := n.guard(q, func(p *Parser) (err error) {
+		newAST, err = p.parseSQL(p.newBackendQuery(q))
+		return err
+	})
+	var normalized string
+	if reason != "" {
+		if scan {
+			normalized = n.fallBack(q, reason, err)
+		} else {
+			n.LastFallback = reason
+		}
+	} else if newAST != nil {
+		n.finish()
+	}
+	n.instrument(q, reason, false)
+	if reason != "" {
+		return nil, normalized
+	}
+	return newAST, ""
+}
+
+// prepare resets the Parser for q, and records what can be learned from
+// q's text before it's parsed.  The query to parse is returned, with any
+// executable comments expanded.
+func (n *Parser) prepare(q string) string {
+	n.Result.reset()
+	n.leadingHints = ""
+	n.insertVariant = ""
+	n.subqueryDepth = 0
+	n.tableAliases = make(map[string]string)
+	n.timings = nil
+
+	if q == "" {
+		return q
+	}
+	if n.MaxQueryLength > 0 && len(q) > n.MaxQueryLength {
+		// nothing more is done with a query that's too long to parse.
+		return q
+	}
+
+	q = expandExecutableComments(q, true)
+
+	// comments are inspected before lowercasing, tag values and hint
+	// arguments are case sensitive.
+	n.rawComments = extractBlockComments(q)
+	n.rawCommentsUsed = make([]bool, len(n.rawComments))
+	n.LastCommentTags = commentTags(n.rawComments)
+	for _, c := range n.rawComments {
+		if isHintComment(c) {
+			n.LastHints = append(n.LastHints, ParseOptimizerHints(c[1:])...)
+		}
+	}
+
+	return q
+}
+
+// finish puts the lists a backend accumulated into their canonical form.
+func (n *Parser) finish() {
 	var lastTables []string
 	for _, t := range n.LastTables {
 		lastTables = append(lastTables, strings.Trim(t, "`"))
@@ -49,33 +342,80 @@ func (n *Parser) NormalizeQuery(q string) string {
 	sort.Sort(sort.StringSlice(lastTables))
 
 	n.LastTables = lastTables
+	sort.Strings(n.LastFunctions)
+	sort.Strings(n.LastNondeterministic)
+}
+
+// parseSQL parses q with sqlparser and transforms it, returning the
+// normalized AST.
+func (n *Parser) parseSQL(q BackendQuery) (sqlparser.SQLNode, error) {
+	start := time.Now()
+	sqlAST, err := sqlparser.Parse(q.Folded)
+	n.observe(PhaseParse, start)
+	if err != nil {
+		return nil, err
+	}
+
+	start = time.Now()
+	newAST := n.NormalizeStatement(sqlAST, q.Variant)
+	n.observe(PhaseTransform, start)
+	return newAST, nil
+}
+
+// NormalizeStatement normalizes stmt, a query parsed with sqlparser,
+// recording what it finds in the Parser's Result, and returns the
+// normalized tree.  variant is the BackendQuery's Variant.  It's for
+// Backends, and is only meaningful while the Parser is calling one.
+func (n *Parser) NormalizeStatement(stmt sqlparser.Statement, variant string) sqlparser.SQLNode {
+	n.insertVariant = variant
+	newAST := transform(stmt, n)
+	if newAST == nil {
+		return nil
+	}
+	if n.StripAliases {
+		aliases := stripAliases(newAST)
+		n.LastTables = removeAliasTables(n.LastTables, aliases, newAST)
+	}
 
-	return string(sqlparser.Serialize(newAST, len(q)))
+	n.LastStatement = n.classifyStatement(stmt)
+	if variant != "" && n.LastStatement == "insert" {
+		n.LastStatement = variant
+	}
+	return newAST
 }
 
-// QuestionMarkExpr is a special SQLNode used to render '?'.  we replace literal values with this in our transformer
+// QuestionMarkExpr is a special SQLNode used to render '?'.  we replace literal values with this in our transformer.
+// Type, if set, is rendered after the '?'.
 type QuestionMarkExpr struct {
+	Type string
 }
 
 func (q *QuestionMarkExpr) Format(buf *sqlparser.TrackedBuffer) {
-	buf.Myprintf("?")
+	buf.Myprintf("?%s", q.Type)
 }
 
 func (q *QuestionMarkExpr) Serialize(runes []rune) []rune {
-	return append(runes, '?')
+	runes = append(runes, '?')
+	return append(runes, []rune(q.Type)...)
 }
 
 func (*QuestionMarkExpr) IExpr()    {}
 func (*QuestionMarkExpr) IValExpr() {}
 
-type EllipsisExpr struct{}
+// EllipsisExpr is a special SQLNode used to render '(...)' in place of a
+// collapsed list.  Bucket, if set, is rendered after the ellipsis.
+type EllipsisExpr struct {
+	Bucket string
+}
 
 func (e *EllipsisExpr) Format(buf *sqlparser.TrackedBuffer) {
-	buf.Myprintf("(...)")
+	buf.Myprintf("(...%s)", e.Bucket)
 }
 
 func (e *EllipsisExpr) Serialize(runes []rune) []rune {
-	return append(runes, []rune("(...)")...)
+	runes = append(runes, []rune("(...")...)
+	runes = append(runes, []rune(e.Bucket)...)
+	return append(runes, ')')
 }
 
 func (*EllipsisExpr) IExpr()     {}
@@ -83,12 +423,25 @@ func (*EllipsisExpr) IValExpr()  {}
 func (*EllipsisExpr) IRowTuple() {}
 
 func (n *Parser) TransformSelect(node *sqlparser.Select) sqlparser.SQLNode {
-	n.addComments(node.Comments)
+	if hints := n.addComments(node.Comments); hints != "" {
+		// Distinct is serialized right after "select ", which is where
+		// the hints belong.
+		node.Distinct = hints + " " + node.Distinct
+	}
 	node.Comments = removeComments(node.Comments)
 	node.SelectExprs, _ = transform(node.SelectExprs, n).(sqlparser.SelectExprs)
 	node.Where, _ = transform(node.Where, n).(*sqlparser.Where)
 	node.From, _ = transform(node.From, n).(sqlparser.TableExprs)
 	node.Limit, _ = transform(node.Limit, n).(*sqlparser.Limit)
+	for _, expr := range node.GroupBy {
+		n.addExprFunctions(expr)
+	}
+	if node.Having != nil {
+		n.addExprFunctions(node.Having.Expr)
+	}
+	for _, order := range node.OrderBy {
+		n.addExprFunctions(order.Expr)
+	}
 	return node
 }
 func (n *Parser) TransformSelectExprs(node sqlparser.SelectExprs) sqlparser.SQLNode {
@@ -100,25 +453,43 @@ func (n *Parser) TransformSelectExprs(node sqlparser.SelectExprs) sqlparser.SQLN
 	return newSlice
 }
 func (n *Parser) TransformUnion(node *sqlparser.Union) sqlparser.SQLNode {
-	node.Left, _ = transform(node.Left, n).(sqlparser.SelectStatement)
-	node.Right, _ = transform(node.Right, n).(sqlparser.SelectStatement)
-	return node
+	// the whole chain of UNIONs is handled here, rather than recursing
+	// into nested Union nodes, so identical branches can be collapsed.
+	branches, types := flattenUnion(node)
+	n.LastMetrics.UnionBranches += len(branches)
+	for i, branch := range branches {
+		branches[i], _ = transform(branch, n).(sqlparser.SelectStatement)
+	}
+	if n.CollapseUnions {
+		branches, types = collapseUnionBranches(branches, types)
+	}
+	if len(branches) == 1 {
+		return branches[0]
+	}
+	return buildUnion(branches, types)
 }
 func (n *Parser) TransformInsert(node *sqlparser.Insert) sqlparser.SQLNode {
-	n.addComments(node.Comments)
+	n.leadingHints = n.addComments(node.Comments)
 	node.Comments = removeComments(node.Comments)
 	node.Table, _ = transform(node.Table, n).(*sqlparser.TableName)
 	node.Rows, _ = transform(node.Rows, n).(sqlparser.InsertRows)
-	node.OnDup, _ = transform(sqlparser.UpdateExprs(node.OnDup), n).(sqlparser.OnDup)
+	if node.OnDup != nil {
+		n.addOnDupColumns(node.OnDup)
+		onDup, _ := transform(sqlparser.UpdateExprs(node.OnDup), n).(sqlparser.UpdateExprs)
+		node.OnDup = sqlparser.OnDup(onDup)
+	}
 	return node
 }
 func (n *Parser) TransformUpdate(node *sqlparser.Update) sqlparser.SQLNode {
-	n.addComments(node.Comments)
+	n.leadingHints = n.addComments(node.Comments)
 	node.Comments = removeComments(node.Comments)
 	node.Table, _ = transform(node.Table, n).(*sqlparser.TableName)
 	node.Exprs, _ = transform(node.Exprs, n).(sqlparser.UpdateExprs)
 	node.Where, _ = transform(node.Where, n).(*sqlparser.Where)
 	node.Limit, _ = transform(node.Limit, n).(*sqlparser.Limit)
+	for _, order := range node.OrderBy {
+		n.addExprFunctions(order.Expr)
+	}
 	return node
 }
 func (n *Parser) TransformUpdateExprs(node sqlparser.UpdateExprs) sqlparser.SQLNode {
@@ -130,11 +501,14 @@ func (n *Parser) TransformUpdateExprs(node sqlparser.UpdateExprs) sqlparser.SQLN
 	return newSlice
 }
 func (n *Parser) TransformDelete(node *sqlparser.Delete) sqlparser.SQLNode {
-	n.addComments(node.Comments)
+	n.leadingHints = n.addComments(node.Comments)
 	node.Comments = removeComments(node.Comments)
 	node.Table, _ = transform(node.Table, n).(*sqlparser.TableName)
 	node.Where, _ = transform(node.Where, n).(*sqlparser.Where)
 	node.Limit, _ = transform(node.Limit, n).(*sqlparser.Limit)
+	for _, order := range node.OrderBy {
+		n.addExprFunctions(order.Expr)
+	}
 	return node
 }
 func (n *Parser) TransformSet(node *sqlparser.Set) sqlparser.SQLNode {
@@ -173,6 +547,7 @@ func (n *Parser) TransformAliasedTableExpr(node *sqlparser.AliasedTableExpr) sql
 	if node == nil {
 		return nil
 	}
+	n.addTableAlias(node)
 	node.Expr, _ = transform(node.Expr, n).(sqlparser.SimpleTableExpr)
 	return node
 }
@@ -182,6 +557,7 @@ func (n *Parser) TransformTableName(node *sqlparser.TableName) sqlparser.SQLNode
 		return nil
 	}
 
+	n.LastMetrics.Tables++
 	n.addTableName(sqlparser.String(node))
 	return node
 }
@@ -198,9 +574,14 @@ func (n *Parser) TransformJoinTableExpr(node *sqlparser.JoinTableExpr) sqlparser
 	if node == nil {
 		return nil
 	}
+	if n.CanonicalPredicates {
+		node.Join = canonicalJoin(node.Join)
+	}
+	n.LastMetrics.Joins[strings.TrimSpace(node.Join)]++
 	node.LeftExpr, _ = transform(node.LeftExpr, n).(sqlparser.TableExpr)
 	node.RightExpr, _ = transform(node.RightExpr, n).(sqlparser.TableExpr)
 	node.On, _ = transform(node.On, n).(sqlparser.BoolExpr)
+	n.addJoinEdge(node)
 	return node
 }
 func (n *Parser) TransformIndexHints(node *sqlparser.IndexHints) sqlparser.SQLNode /* needed? */ {
@@ -210,6 +591,9 @@ func (n *Parser) TransformWhere(node *sqlparser.Where) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	if node.Type == sqlparser.AST_WHERE {
+		n.LastMetrics.HasWhere = true
+	}
 
 	node.Expr, _ = transform(node.Expr, n).(sqlparser.BoolExpr)
 	return node
@@ -218,6 +602,9 @@ func (n *Parser) TransformAndExpr(node *sqlparser.AndExpr) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	if n.CanonicalPredicates {
+		return n.canonicalAnd(node)
+	}
 	node.Left, _ = transform(node.Left, n).(sqlparser.BoolExpr)
 	node.Right, _ = transform(node.Right, n).(sqlparser.BoolExpr)
 	return node
@@ -226,6 +613,9 @@ func (n *Parser) TransformOrExpr(node *sqlparser.OrExpr) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	if n.CanonicalPredicates {
+		return n.canonicalOr(node)
+	}
 	node.Left, _ = transform(node.Left, n).(sqlparser.BoolExpr)
 	node.Right, _ = transform(node.Right, n).(sqlparser.BoolExpr)
 	return node
@@ -235,6 +625,9 @@ func (n *Parser) TransformNotExpr(node *sqlparser.NotExpr) sqlparser.SQLNode {
 		return nil
 	}
 	node.Expr, _ = transform(node.Expr, n).(sqlparser.BoolExpr)
+	if n.CanonicalPredicates {
+		return canonicalNot(node)
+	}
 	return node
 }
 func (n *Parser) TransformParenBoolExpr(node *sqlparser.ParenBoolExpr) sqlparser.SQLNode {
@@ -249,19 +642,57 @@ func (n *Parser) TransformComparisonExpr(node *sqlparser.ComparisonExpr) sqlpars
 	if node == nil {
 		return nil
 	}
+	n.LastMetrics.Predicates++
 	node.Left, _ = transform(node.Left, n).(sqlparser.ValExpr)
 
-	if node.Operator == sqlparser.AST_IN && sqlparser.IsSimpleTuple(node.Right) {
-		node.Right = &EllipsisExpr{}
+	collapse := node.Operator == sqlparser.AST_IN || (n.CanonicalPredicates && node.Operator == sqlparser.AST_NOT_IN)
+	if collapse && sqlparser.IsSimpleTuple(node.Right) {
+		node.Right = n.collapseINList(node.Right)
 	} else {
 		node.Right, _ = transform(node.Right, n).(sqlparser.ValExpr)
 	}
+	if n.CanonicalPredicates {
+		canonicalComparison(node)
+	}
 	return node
 }
+
+// collapseINList replaces a simple IN tuple with an ellipsis, recording the
+// tuple's arity and optionally keeping its size bucket.
+func (n *Parser) collapseINList(node sqlparser.ValExpr) *EllipsisExpr {
+	tuple, ok := node.(sqlparser.ValTuple)
+	if !ok {
+		// a list arg (::name), there's no arity to record.
+		return &EllipsisExpr{}
+	}
+
+	n.LastINListSizes = append(n.LastINListSizes, len(tuple))
+	if !n.BucketINLists {
+		return &EllipsisExpr{}
+	}
+	return &EllipsisExpr{Bucket: inListBucket(len(tuple))}
+}
+
+// inListBucket returns the order-of-magnitude bucket an IN list of size
+// falls into.
+func inListBucket(size int) string {
+	switch {
+	case size <= 10:
+		return "1-10"
+	case size <= 100:
+		return "11-100"
+	case size <= 1000:
+		return "101-1000"
+	default:
+		return "1000+"
+	}
+}
+
 func (n *Parser) TransformRangeCond(node *sqlparser.RangeCond) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	n.LastMetrics.Predicates++
 	node.Left, _ = transform(node.Left, n).(sqlparser.ValExpr)
 	node.From, _ = transform(node.From, n).(sqlparser.ValExpr)
 	node.To, _ = transform(node.To, n).(sqlparser.ValExpr)
@@ -271,22 +702,23 @@ func (n *Parser) TransformExistsExpr(node *sqlparser.ExistsExpr) sqlparser.SQLNo
 	if node == nil {
 		return nil
 	}
+	n.LastMetrics.Predicates++
 	node.Subquery, _ = transform(node.Subquery, n).(*sqlparser.Subquery)
 	return node
 }
 func (n *Parser) TransformTimestampVal(node sqlparser.TimestampVal) sqlparser.SQLNode {
-	return &QuestionMarkExpr{}
+	return n.placeholder("t")
 }
 func (n *Parser) TransformBinaryVal(node sqlparser.BinaryVal) sqlparser.SQLNode {
-	return &QuestionMarkExpr{}
+	return n.placeholder("x")
 }
 func (n *Parser) TransformStrVal(node sqlparser.StrVal) sqlparser.SQLNode {
-	return &QuestionMarkExpr{}
+	return n.placeholder("s")
 }
 func (n *Parser) TransformNumVal(node sqlparser.NumVal) sqlparser.SQLNode {
-	return &QuestionMarkExpr{}
+	return n.placeholder("n")
 }
-func (n *Parser) TransformValArg(node *sqlparser.ValArg) sqlparser.SQLNode {
+func (n *Parser) TransformValArg(node sqlparser.ValArg) sqlparser.SQLNode {
 	return &QuestionMarkExpr{}
 }
 func (n *Parser) TransformValTuple(node sqlparser.ValTuple) sqlparser.SQLNode {
@@ -298,13 +730,26 @@ func (n *Parser) TransformValTuple(node sqlparser.ValTuple) sqlparser.SQLNode {
 	return newSlice
 }
 func (n *Parser) TransformNullVal(node *sqlparser.NullVal) sqlparser.SQLNode {
+	if n.TypedPlaceholders {
+		return n.placeholder("null")
+	}
 	return node
 }
+
+// placeholder returns the '?' a literal is replaced with, tagged with the
+// literal's type if TypedPlaceholders is set.
+func (n *Parser) placeholder(typ string) *QuestionMarkExpr {
+	if !n.TypedPlaceholders {
+		return &QuestionMarkExpr{}
+	}
+	return &QuestionMarkExpr{Type: typ}
+}
 func (n *Parser) TransformColName(node *sqlparser.ColName) sqlparser.SQLNode {
 	if node.Qualifier != nil {
 		quals := strings.Split(string(node.Qualifier), ".")
 		n.addTableName(quals[len(quals)-1])
 	}
+	n.addColumnFunction(node)
 
 	return node
 }
@@ -312,7 +757,12 @@ func (n *Parser) TransformSubquery(node *sqlparser.Subquery) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	n.subqueryDepth++
+	if n.subqueryDepth > n.LastMetrics.SubqueryDepth {
+		n.LastMetrics.SubqueryDepth = n.subqueryDepth
+	}
 	node.Select, _ = transform(node.Select, n).(sqlparser.SelectStatement)
+	n.subqueryDepth--
 	return node
 }
 func (n *Parser) TransformBinaryExpr(node *sqlparser.BinaryExpr) sqlparser.SQLNode {
@@ -334,6 +784,12 @@ func (n *Parser) TransformFuncExpr(node *sqlparser.FuncExpr) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
+	if l := len(node.Name); l > 2 && node.Name[0] == '`' && node.Name[l-1] == '`' {
+		// quoted by quoteOnDupValues, the quotes don't change its meaning.
+		node.Name = node.Name[1 : l-1]
+	}
+	lowerRunes(node.Name)
+	n.addFunction(string(node.Name))
 	node.Exprs, _ = transform(node.Exprs, n).(sqlparser.SelectExprs)
 	return node
 }
@@ -363,8 +819,9 @@ func (n *Parser) TransformLimit(node *sqlparser.Limit) sqlparser.SQLNode {
 	if node == nil {
 		return nil
 	}
-	node.Offset, _ = transform(node.Offset, n).(sqlparser.ValExpr)
-	node.Rowcount, _ = transform(node.Rowcount, n).(sqlparser.ValExpr)
+	n.addLimit(node)
+	node.Offset = n.normalizeLimitValue(node.Offset)
+	node.Rowcount = n.normalizeLimitValue(node.Rowcount)
 	return node
 }
 func (n *Parser) TransformUpdateExpr(node *sqlparser.UpdateExpr) sqlparser.SQLNode {
@@ -376,6 +833,12 @@ func (n *Parser) TransformUpdateExpr(node *sqlparser.UpdateExpr) sqlparser.SQLNo
 }
 
 func (n *Parser) TransformValues(node sqlparser.Values) sqlparser.SQLNode {
+	n.LastRowCount += len(node)
+	if n.CollapseValues && len(node) > 1 {
+		rowTuple, _ := transform(node[0], n).(sqlparser.RowTuple)
+		return sqlparser.Values{rowTuple, &EllipsisExpr{}}
+	}
+
 	var newSlice sqlparser.Values
 	for _, rt := range node {
 		rowTuple, _ := transform(rt, n).(sqlparser.RowTuple)
@@ -401,13 +864,35 @@ func (n *Parser) TransformAliasedTablExpr(node *sqlparser.AliasedTableExpr) sqlp
 	return node
 }
 
-func (n *Parser) addComments(comments sqlparser.Comments) {
-	if comments == nil {
-		return
-	}
+// addComments accumulates a statement's comments, returning the normalized
+// form of any optimizer hints among them.
+func (n *Parser) addComments(comments sqlparser.Comments) string {
+	var hints []string
 	for _, c := range comments {
+		if body, ok := n.matchRawComment(c); ok && isHintComment(body) {
+			hints = append(hints, normalizeHintComment(body[1:], n.PreserveIdentifierCase))
+			continue
+		}
 		n.LastComments = append(n.LastComments, strings.TrimSpace(string(c)))
 	}
+	return strings.Join(hints, " ")
+}
+
+// matchRawComment finds the (not yet matched) raw comment body that
+// sqlparser parsed as comment.
+func (n *Parser) matchRawComment(comment []rune) (string, bool) {
+	want := strings.TrimSpace(string(comment))
+	for i, raw := range n.rawComments {
+		if n.rawCommentsUsed[i] || raw == "" {
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(raw)
+		if strings.EqualFold(strings.TrimSpace(raw[size:]), want) {
+			n.rawCommentsUsed[i] = true
+			return raw, true
+		}
+	}
+	return "", false
 }
 
 func removeComments(comments sqlparser.Comments) sqlparser.Comments {
@@ -431,7 +916,7 @@ func (n *Parser) addTableName(tableNameStr string) {
 	}
 }
 
-func classifyStatement(node sqlparser.SQLNode) string {
+func (n *Parser) classifyStatement(node sqlparser.SQLNode) string {
 	if node == nil {
 		return ""
 	}
@@ -443,6 +928,9 @@ func classifyStatement(node sqlparser.SQLNode) string {
 	case unionType:
 		return "union"
 	case insertType:
+		if len(node.(*sqlparser.Insert).OnDup) > 0 {
+			return "upsert"
+		}
 		return "insert"
 	case updateType:
 		return "update"
@@ -457,7 +945,7 @@ func classifyStatement(node sqlparser.SQLNode) string {
 	case ddlType:
 		return ""
 	default:
-		log.Printf("classifyStatement doesn't handle %+v", reflect.TypeOf(node))
+		n.logger().Debug("classifyStatement doesn't handle node", "type", nodeType)
 		return ""
 	}
 }
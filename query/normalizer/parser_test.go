@@ -2,6 +2,7 @@ package normalizer_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/honeycombio/mysqltools/query/normalizer"
@@ -135,6 +136,13 @@ var parserTests = []struct {
 		[]string{"tablename"},
 		[]string{},
 	},
+	{"bind placeholders",
+		"SELECT `colname` FROM `tablename` WHERE id = ? AND name = :name",
+		"select `colname` from `tablename` where id = ? and name = ?",
+		"select",
+		[]string{"tablename"},
+		[]string{},
+	},
 	//{"alter table", "ALTER TABLE `tablename` ADD COLUMN `text` VARCHAR(100) NOT NULL AFTER `before_text`", "alter table tablename add column text varchar(?) not null after before_text"},
 }
 
@@ -187,3 +195,252 @@ func TestParserNormalization(t *testing.T) {
 	}
 
 }
+
+var inListBucketTests = []struct {
+	ID             string
+	Input          string
+	ExpectedOutput string
+	ExpectedSizes  []int
+}{
+	{"small IN list",
+		"SELECT `colname` FROM `tablename` WHERE id IN (1, 2, 3, 4, 5)",
+		"select `colname` from `tablename` where id in (...1-10)",
+		[]int{5},
+	},
+	{"multiple IN lists",
+		"SELECT `colname` FROM `tablename` WHERE id IN (1, 2) AND name IN ('a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l')",
+		"select `colname` from `tablename` where id in (...1-10) and name in (...11-100)",
+		[]int{2, 12},
+	},
+	{"subqueries aren't collapsed",
+		"SELECT `colname` FROM `tablename` WHERE id IN (SELECT id FROM tablename2)",
+		"select `colname` from `tablename` where id in (select id from tablename2)",
+		[]int{},
+	},
+}
+
+func TestParserINListBuckets(t *testing.T) {
+	n := &normalizer.Parser{BucketINLists: true}
+
+	for _, test := range inListBucketTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if fmt.Sprint(test.ExpectedSizes) != fmt.Sprint(n.LastINListSizes) {
+			t.Error("test '" + test.ID + "' failed IN list sizes.  actual = " + fmt.Sprint(n.LastINListSizes))
+		}
+	}
+
+	values := make([]string, 1500)
+	for i := range values {
+		values[i] = fmt.Sprint(i)
+	}
+	actual := n.NormalizeQuery("SELECT id FROM tablename WHERE id IN (" + strings.Join(values, ", ") + ")")
+	if actual != "select id from tablename where id in (...1000+)" {
+		t.Error("large IN list failed normalization.  actual = " + actual)
+	}
+	if len(n.LastINListSizes) != 1 || n.LastINListSizes[0] != 1500 {
+		t.Error("large IN list failed IN list sizes.  actual = " + fmt.Sprint(n.LastINListSizes))
+	}
+}
+
+var collapseValuesTests = []struct {
+	ID               string
+	Input            string
+	ExpectedOutput   string
+	ExpectedRowCount int
+}{
+	{"single row insert",
+		"INSERT INTO `tablename` (intCol, floatCol) VALUES (12345, 1.2345)",
+		"insert into `tablename`(intcol,floatcol) values (?, ?)",
+		1,
+	},
+	{"multi row insert",
+		"INSERT INTO `tablename` (intCol, floatCol) VALUES (1, 1.5), (2, 2.5), (3, 3.5)",
+		"insert into `tablename`(intcol,floatcol) values (?, ?), (...)",
+		3,
+	},
+	{"insert with subquery",
+		"INSERT INTO `tablename` (intCol, floatCol) SELECT intCol2, floatCol2 FROM sourceTable WHERE id = 12345",
+		"insert into `tablename`(intcol,floatcol) select intcol2,floatcol2 from sourcetable where id = ?",
+		0,
+	},
+}
+
+func TestParserCollapseValues(t *testing.T) {
+	n := &normalizer.Parser{CollapseValues: true}
+
+	for _, test := range collapseValuesTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedRowCount != n.LastRowCount {
+			t.Error("test '" + test.ID + "' failed row count.  actual = " + fmt.Sprint(n.LastRowCount))
+		}
+	}
+}
+
+var typedPlaceholderTests = []struct {
+	ID             string
+	Input          string
+	ExpectedOutput string
+}{
+	{"number",
+		"SELECT colname FROM tablename WHERE id = 5",
+		"select colname from tablename where id = ?n",
+	},
+	{"string",
+		"SELECT colname FROM tablename WHERE id = '5'",
+		"select colname from tablename where id = ?s",
+	},
+	{"binary",
+		"SELECT colname FROM tablename WHERE id = BINARY 'abc'",
+		"select colname from tablename where id = ?x",
+	},
+	{"timestamp",
+		"SELECT colname FROM tablename WHERE created > TIMESTAMP '2018-01-01 00:00:00'",
+		"select colname from tablename where created > ?t",
+	},
+	{"null",
+		"INSERT INTO tablename (a, b, c) VALUES (1.5, 'x', NULL)",
+		"insert into tablename(a,b,c) values (?n, ?s, ?null)",
+	},
+	{"bind placeholder",
+		"SELECT colname FROM tablename WHERE id = ?",
+		"select colname from tablename where id = ?",
+	},
+}
+
+func TestParserTypedPlaceholders(t *testing.T) {
+	n := &normalizer.Parser{TypedPlaceholders: true}
+
+	for _, test := range typedPlaceholderTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+	}
+
+	// the default output is untouched, NULL included.
+	n = &normalizer.Parser{}
+	expected := "insert into tablename(a,b,c) values (?, ?, null)"
+	if actual := n.NormalizeQuery(typedPlaceholderTests[4].Input); expected != actual {
+		t.Error("untyped placeholders failed.  actual = " + actual)
+	}
+}
+
+var preserveCaseTests = []struct {
+	ID             string
+	Input          string
+	ExpectedOutput string
+	ExpectedTables []string
+}{
+	{"select",
+		"SELECT UserName FROM Users WHERE Id = 5",
+		"select UserName from Users where Id = ?",
+		[]string{"Users"},
+	},
+	{"tables differing only in case",
+		"SELECT Users.Id FROM Users JOIN users ON Users.Id = users.Id",
+		"select Users.Id from Users join users on Users.Id = users.Id",
+		[]string{"Users", "users"},
+	},
+	{"functions folded",
+		"SELECT COUNT(*), MAX(Score) FROM Games WHERE Created < NOW() GROUP BY Player HAVING SUM(Score) > 10",
+		"select count(*),max(Score) from Games where Created < now() group by Player having sum(Score) > 10",
+		[]string{"Games"},
+	},
+	{"comment kept as is",
+		"SELECT /* Hello World */ Id FROM Users",
+		"select Id from Users",
+		[]string{"Users"},
+	},
+	{"hint arguments",
+		"SELECT /*+ INDEX(Users IdxName) */ Id FROM Users",
+		"select /*+ index(Users IdxName) */ Id from Users",
+		[]string{"Users"},
+	},
+	{"call",
+		"CALL `Db`.`MyProc`(1)",
+		"call Db.MyProc(?)",
+		[]string{"Db.MyProc"},
+	},
+	{"scanner fallback",
+		"SELECT Id FROM Users WHERE",
+		"select Id from Users where",
+		nil,
+	},
+}
+
+func TestParserPreserveIdentifierCase(t *testing.T) {
+	n := &normalizer.Parser{PreserveIdentifierCase: true}
+
+	for _, test := range preserveCaseTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if fmt.Sprint(test.ExpectedTables) != fmt.Sprint(n.LastTables) {
+			t.Error("test '" + test.ID + "' failed tables.  actual = " + fmt.Sprint(n.LastTables))
+		}
+	}
+
+	if actual := n.LastComments; len(actual) != 0 {
+		t.Error("unexpected comments " + fmt.Sprint(actual))
+	}
+	n.NormalizeQuery(preserveCaseTests[3].Input)
+	if fmt.Sprint(n.LastComments) != "[Hello World]" {
+		t.Error("comment case not preserved.  actual = " + fmt.Sprint(n.LastComments))
+	}
+}
+
+var unionTests = []struct {
+	ID               string
+	Input            string
+	ExpectedOutput   string
+	ExpectedBranches int
+}{
+	{"union and union all kept distinct",
+		"SELECT a FROM t1 UNION SELECT a FROM t2 UNION ALL SELECT a FROM t3",
+		"select a from t1 union select a from t2 union all select a from t3",
+		3,
+	},
+	{"identical branches collapsed",
+		"SELECT a FROM t WHERE id = 1 UNION ALL SELECT a FROM t WHERE id = 2 UNION ALL SELECT a FROM t WHERE id = 3",
+		"select a from t where id = ? union all ...",
+		3,
+	},
+	{"runs collapsed separately",
+		"SELECT a FROM t1 UNION SELECT b FROM t2 WHERE id = 1 UNION SELECT b FROM t2 WHERE id = 2 UNION ALL SELECT b FROM t2 WHERE id = 3 UNION ALL SELECT c FROM t3",
+		"select a from t1 union select b from t2 where id = ? union ... union all select b from t2 where id = ? union all select c from t3",
+		5,
+	},
+	{"different branches kept",
+		"SELECT a FROM t1 UNION ALL SELECT a FROM t2",
+		"select a from t1 union all select a from t2",
+		2,
+	},
+}
+
+func TestParserCollapseUnions(t *testing.T) {
+	n := &normalizer.Parser{CollapseUnions: true}
+
+	for _, test := range unionTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedBranches != n.LastMetrics.UnionBranches {
+			t.Error("test '" + test.ID + "' failed branch count.  actual = " + fmt.Sprint(n.LastMetrics.UnionBranches))
+		}
+	}
+
+	// without the option every branch is kept.
+	n = &normalizer.Parser{}
+	expected := "select a from t where id = ? union all select a from t where id = ? union all select a from t where id = ?"
+	if actual := n.NormalizeQuery(unionTests[1].Input); expected != actual {
+		t.Error("uncollapsed union failed.  actual = " + actual)
+	}
+}
@@ -0,0 +1,177 @@
+package normalizer
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PreparedStatement is a statement created with PREPARE.
+type PreparedStatement struct {
+	// Name is the statement's (lowercased) name.
+	Name string
+	// Query is the normalized form of the prepared SQL, empty if it was
+	// given in a user variable or the statement wasn't prepared in this
+	// session.
+	Query string
+	// Statement is the prepared SQL's statement type, e.g. "select".
+	Statement string
+}
+
+// Session tracks the statements prepared on a single client connection, so
+// that EXECUTE and DEALLOCATE PREPARE can be linked back to the query they
+// refer to.
+type Session struct {
+	// a Parser that times out leaves the statement it was normalizing
+	// running, so the Session may be changed from another goroutine.
+	mu       sync.Mutex
+	prepared map[string]PreparedStatement
+}
+
+// NewSession returns a session with no prepared statements.
+func NewSession() *Session {
+	return &Session{prepared: make(map[string]PreparedStatement)}
+}
+
+// Prepared returns the statement prepared with the given name.
+func (s *Session) Prepared(name string) (PreparedStatement, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stmt, ok := s.prepared[strings.ToLower(name)]
+	return stmt, ok
+}
+
+func (s *Session) prepare(stmt PreparedStatement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prepared[stmt.Name] = stmt
+}
+
+func (s *Session) deallocate(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.prepared, name)
+}
+
+func (n *Parser) session() *Session {
+	if n.Session == nil {
+		n.Session = NewSession()
+	}
+	return n.Session
+}
+
+// normalizePrepared normalizes q if it's a PREPARE, EXECUTE or DEALLOCATE
+// PREPARE statement, none of which sqlparser understands.  The prepared SQL
+// of a PREPARE is itself normalized, filling in the Result, and remembered
+// in the Parser's Session.
+func (n *Parser) normalizePrepared(q string) (string, bool) {
+	words := strings.Fields(strings.TrimRight(strings.TrimSpace(q), ";"))
+	if len(words) < 2 {
+		return "", false
+	}
+
+	switch strings.ToLower(words[0]) {
+	case "prepare":
+		if len(words) < 4 || strings.ToLower(words[2]) != "from" {
+			return "", false
+		}
+		name := preparedName(words[1])
+
+		// the SQL may contain whitespace, so take it from q itself.
+		source := strings.TrimRight(skipWords(q, 3), "; \t\r\n")
+
+		stmt := PreparedStatement{Name: name}
+		var normalized string
+		if sql, ok := unquoteSQLString(source); ok {
+			// the prepared SQL is part of the PREPARE, it isn't counted
+			// or cached as a query of its own.
+			inner := *n
+			inner.Cache = nil
+			inner.Instrumentation = nil
+			stmt.Query = inner.NormalizeQuery(sql)
+			n.Result = inner.Result
+			stmt.Statement = n.LastStatement
+			normalized = "prepare " + name + " from '" + stmt.Query + "'"
+		} else {
+			// e.g. PREPARE stmt FROM @sql
+			normalized = "prepare " + name + " from " + strings.ToLower(source)
+		}
+
+		n.session().prepare(stmt)
+		n.LastStatement = "prepare"
+		n.LastPrepared = stmt
+		return normalized, true
+
+	case "execute":
+		name := preparedName(words[1])
+		normalized := "execute " + name
+		if len(words) > 3 && strings.ToLower(words[2]) == "using" {
+			vars := strings.Split(strings.ToLower(strings.Join(words[3:], "")), ",")
+			normalized += " using " + strings.Join(vars, ", ")
+		}
+
+		n.LastStatement = "execute"
+		n.LastPrepared = n.preparedStatement(name)
+		return normalized, true
+
+	case "deallocate", "drop":
+		if len(words) != 3 || strings.ToLower(words[1]) != "prepare" {
+			return "", false
+		}
+		name := preparedName(words[2])
+
+		n.LastStatement = "deallocate"
+		n.LastPrepared = n.preparedStatement(name)
+		n.session().deallocate(name)
+		return strings.ToLower(words[0]) + " prepare " + name, true
+	}
+
+	return "", false
+}
+
+// preparedStatement returns the statement prepared with the given name in
+// the Parser's session, or one with just the name if there isn't one.
+func (n *Parser) preparedStatement(name string) PreparedStatement {
+	if stmt, ok := n.session().Prepared(name); ok {
+		return stmt
+	}
+	return PreparedStatement{Name: name}
+}
+
+func preparedName(word string) string {
+	return strings.ToLower(strings.Trim(word, "`"))
+}
+
+// skipWords returns s without its first count whitespace separated words.
+func skipWords(s string, count int) string {
+	for i := 0; i < count; i++ {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		s = strings.TrimLeftFunc(s, func(r rune) bool { return !unicode.IsSpace(r) })
+	}
+	return strings.TrimLeftFunc(s, unicode.IsSpace)
+}
+
+// unquoteSQLString returns the contents of a single or double quoted string
+// literal, with backslash and doubled quote escapes resolved.
+func unquoteSQLString(s string) (string, bool) {
+	if len(s) < 2 || (s[0] != '\'' && s[0] != '"') || s[len(s)-1] != s[0] {
+		return "", false
+	}
+	quote := s[0]
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s)-1 {
+			i++
+			switch c = s[i]; c {
+			case 'n', 'r', 't':
+				c = ' '
+			}
+		} else if c == quote && i+1 < len(s)-1 && s[i+1] == quote {
+			i++
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), true
+}
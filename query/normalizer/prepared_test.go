@@ -0,0 +1,83 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+// preparedTests run in order against a single session.
+var preparedTests = []struct {
+	ID                string
+	Input             string
+	ExpectedOutput    string
+	ExpectedStatement string
+	ExpectedPrepared  normalizer.PreparedStatement
+}{
+	{"prepare",
+		"PREPARE stmt1 FROM 'SELECT colname FROM tablename WHERE id = ? AND name = \\'x\\''",
+		"prepare stmt1 from 'select colname from tablename where id = ? and name = ?'",
+		"prepare",
+		normalizer.PreparedStatement{Name: "stmt1", Query: "select colname from tablename where id = ? and name = ?", Statement: "select"},
+	},
+	{"prepare from variable",
+		"PREPARE Stmt2 FROM @sql",
+		"prepare stmt2 from @sql",
+		"prepare",
+		normalizer.PreparedStatement{Name: "stmt2"},
+	},
+	{"execute",
+		"EXECUTE stmt1 USING @a, @b",
+		"execute stmt1 using @a, @b",
+		"execute",
+		normalizer.PreparedStatement{Name: "stmt1", Query: "select colname from tablename where id = ? and name = ?", Statement: "select"},
+	},
+	{"execute unknown",
+		"EXECUTE stmt3",
+		"execute stmt3",
+		"execute",
+		normalizer.PreparedStatement{Name: "stmt3"},
+	},
+	{"deallocate",
+		"DEALLOCATE PREPARE stmt1",
+		"deallocate prepare stmt1",
+		"deallocate",
+		normalizer.PreparedStatement{Name: "stmt1", Query: "select colname from tablename where id = ? and name = ?", Statement: "select"},
+	},
+	{"execute after deallocate",
+		"EXECUTE stmt1 USING @a,@b;",
+		"execute stmt1 using @a, @b",
+		"execute",
+		normalizer.PreparedStatement{Name: "stmt1"},
+	},
+}
+
+func TestParserPreparedStatements(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range preparedTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedStatement != n.LastStatement {
+			t.Error("test '" + test.ID + "' failed statement.  actual = " + n.LastStatement)
+		}
+		if test.ExpectedPrepared != n.LastPrepared {
+			t.Error("test '" + test.ID + "' failed prepared statement.  actual = " + fmt.Sprintf("%+v", n.LastPrepared))
+		}
+	}
+
+	n.NormalizeQuery(preparedTests[0].Input)
+	if fmt.Sprint(n.LastTables) != "[tablename]" {
+		t.Error("prepare failed tables.  actual = " + fmt.Sprint(n.LastTables))
+	}
+
+	// another session doesn't see the first's statements.
+	other := &normalizer.Parser{Session: normalizer.NewSession()}
+	other.NormalizeQuery("EXECUTE stmt1")
+	if other.LastPrepared.Query != "" {
+		t.Error("prepared statement leaked between sessions")
+	}
+}
@@ -0,0 +1,340 @@
+package normalizer
+
+import (
+	"strings"
+)
+
+// bodyStartWords are the words a stored routine's body can start with.
+var bodyStartWords = map[string]bool{
+	"begin": true, "select": true, "insert": true, "update": true,
+	"delete": true, "replace": true, "set": true, "call": true, "if": true,
+	"while": true, "loop": true, "repeat": true, "case": true,
+	"return": true, "do": true,
+}
+
+// routineToken is a word, quoted string or punctuation character in a
+// statement sqlparser can't parse.  Parenthesis tokens have the depth of
+// the parentheses they enclose, every other token the depth it's at.
+type routineToken struct {
+	text       string
+	start, end int
+	depth      int
+}
+
+// lower returns the token's text in lowercase.
+func (t routineToken) lower() string {
+	return strings.ToLower(t.text)
+}
+
+// routineTokens splits q into tokens, skipping whitespace and comments.
+func routineTokens(q string) []routineToken {
+	var tokens []routineToken
+	depth := 0
+
+	for i := 0; i < len(q); {
+		c := q[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(q[i:], "/*"):
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += 2 + end + 2
+			continue
+		case strings.HasPrefix(q[i:], "-- ") || c == '#':
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(q) && q[i] != c; i++ {
+				if q[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+			i++
+			if i > len(q) {
+				i = len(q)
+			}
+		case isWordByte(c) || c == '.':
+			for i < len(q) && (isWordByte(q[i]) || q[i] == '.') {
+				i++
+			}
+		case c == '(':
+			i++
+			depth++
+		case c == ')':
+			i++
+			depth--
+		default:
+			i++
+		}
+
+		tokenDepth := depth
+		if c == '(' {
+			tokenDepth--
+		}
+		tokens = append(tokens, routineToken{text: q[start:i], start: start, end: i, depth: tokenDepth})
+	}
+
+	return tokens
+}
+
+// normalizeRoutine normalizes q if it's a CALL, or the CREATE of a stored
+// procedure, function or trigger with a body.  Neither is understood by
+// sqlparser.
+func (n *Parser) normalizeRoutine(q string) (string, bool) {
+	tokens := routineTokens(q)
+	if len(tokens) < 2 {
+		return "", false
+	}
+
+	switch tokens[0].lower() {
+	case "call":
+		return n.normalizeCall(q, tokens)
+	case "create":
+		return n.normalizeCreateRoutine(q, tokens)
+	}
+	return "", false
+}
+
+// normalizeCall normalizes a CALL statement, reporting the procedure in
+// LastTables.  false is returned if the CALL doesn't name a procedure.
+func (n *Parser) normalizeCall(q string, tokens []routineToken) (string, bool) {
+	if !isNameToken(tokens[1]) {
+		return "", false
+	}
+	name := tokens[1].text
+	i := 2
+	for ; i < len(tokens) && (isNameToken(tokens[i]) || tokens[i].text[0] == '.'); i++ {
+		// e.g. `db`.`proc`
+		name += tokens[i].text
+	}
+	name = strings.Replace(name, "`", "", -1)
+	if !n.PreserveIdentifierCase {
+		name = strings.ToLower(name)
+	}
+	n.LastStatement = "call"
+	n.LastMetrics.Tables++
+	n.addTableName(name)
+
+	if i == len(tokens) || tokens[i].text != "(" {
+		return "call " + name, true
+	}
+
+	s := &Scanner{PreserveIdentifierCase: n.PreserveIdentifierCase}
+	var args []string
+	argStart := tokens[i].end
+	for i++; i < len(tokens); i++ {
+		tok := tokens[i]
+		if (tok.depth == 1 && tok.text == ",") || (tok.depth == 0 && tok.text == ")") {
+			if arg := strings.TrimSpace(q[argStart:tok.start]); arg != "" {
+				// the Scanner only replaces numbers that follow punctuation.
+				arg = s.NormalizeQuery("(" + arg + ")")
+				args = append(args, arg[1:len(arg)-1])
+			}
+			argStart = tok.end
+		}
+		if tok.depth == 0 && tok.text == ")" {
+			break
+		}
+	}
+	return "call " + name + "(" + strings.Join(args, ", ") + ")", true
+}
+
+// isNameToken returns true if t is an identifier, quoted or not.
+func isNameToken(t routineToken) bool {
+	return isWordByte(t.text[0]) || t.text[0] == '`'
+}
+
+// normalizeCreateRoutine normalizes the CREATE of a stored procedure,
+// function or trigger.  The statements in the routine's body are
+// fingerprinted one by one into LastBodyStatements, the tables and
+// functions they use are reported as the CREATE's own, and the body is
+// left out of the CREATE's fingerprint.
+func (n *Parser) normalizeCreateRoutine(q string, tokens []routineToken) (string, bool) {
+	kind := -1
+	for i := 1; i < len(tokens) && i < 8; i++ {
+		switch tokens[i].lower() {
+		case "procedure", "function", "trigger":
+			kind = i
+		}
+		if kind >= 0 {
+			break
+		}
+	}
+	if kind < 0 || kind+1 >= len(tokens) {
+		return "", false
+	}
+
+	body := routineBodyStart(tokens, kind)
+	if body < 0 {
+		// e.g. a loadable function, CREATE FUNCTION f RETURNS INTEGER SONAME 'f.so'
+		return "", false
+	}
+
+	s := &Scanner{PreserveIdentifierCase: n.PreserveIdentifierCase}
+	header := s.NormalizeQuery(strings.TrimSpace(q[:tokens[body].start]))
+	statement := "create " + tokens[kind].lower()
+	bodyText := strings.TrimRight(strings.TrimSpace(q[tokens[body].start:]), ";")
+
+	var pieces []string
+	compound := tokens[body].lower() == "begin" || (body+1 < len(tokens) && tokens[body+1].text == ":")
+	if compound {
+		for _, stmt := range splitStatements(bodyText) {
+			if stmt = stripControlFlow(stmt); stmt != "" {
+				pieces = append(pieces, stmt)
+			}
+		}
+	} else {
+		pieces = []string{bodyText}
+	}
+
+	trigger := tokens[kind].lower() == "trigger"
+	var fingerprints []string
+	var tables, functions, nondeterministic []string
+	for _, piece := range pieces {
+		// the body's statements are part of the CREATE, they aren't
+		// counted or cached as queries of their own.
+		inner := *n
+		inner.Cache = nil
+		inner.Instrumentation = nil
+		fingerprints = append(fingerprints, inner.NormalizeQuery(piece))
+		for _, t := range inner.LastTables {
+			// a trigger's NEW and OLD rows aren't tables.
+			if trigger && (strings.EqualFold(t, "new") || strings.EqualFold(t, "old")) {
+				continue
+			}
+			tables = append(tables, t)
+		}
+		functions = append(functions, inner.LastFunctions...)
+		nondeterministic = append(nondeterministic, inner.LastNondeterministic...)
+	}
+
+	n.LastStatement = statement
+	n.LastBodyStatements = fingerprints
+	if trigger {
+		for i := kind + 2; i+1 < body; i++ {
+			if tokens[i].lower() == "on" {
+				table := strings.Replace(tokens[i+1].text, "`", "", -1)
+				if !n.PreserveIdentifierCase {
+					table = strings.ToLower(table)
+				}
+				n.addTableName(table)
+				break
+			}
+		}
+	}
+	for _, t := range tables {
+		n.addTableName(t)
+	}
+	for _, f := range functions {
+		n.LastFunctions = appendUnique(n.LastFunctions, f)
+	}
+	for _, f := range nondeterministic {
+		n.LastNondeterministic = appendUnique(n.LastNondeterministic, f)
+	}
+
+	if compound {
+		return header + " begin ... end", true
+	}
+	return header + " " + fingerprints[0], true
+}
+
+// routineBodyStart returns the index of the token a routine's body starts
+// at, given the index of its PROCEDURE, FUNCTION or TRIGGER keyword, or -1
+// if the routine has no body.
+func routineBodyStart(tokens []routineToken, kind int) int {
+	i := kind + 2
+	if tokens[kind].lower() == "trigger" {
+		// ... FOR EACH ROW [{FOLLOWS | PRECEDES} other_trigger] body
+		for ; i+2 < len(tokens); i++ {
+			if tokens[i].lower() == "for" && tokens[i+1].lower() == "each" && tokens[i+2].lower() == "row" {
+				i += 3
+				if i < len(tokens) && (tokens[i].lower() == "follows" || tokens[i].lower() == "precedes") {
+					i += 2
+				}
+				if i < len(tokens) {
+					return i
+				}
+				return -1
+			}
+		}
+		return -1
+	}
+
+	// skip the parameter list, whose types could be mistaken for the body.
+	for ; i < len(tokens) && (tokens[i].depth > 0 || tokens[i].text == "("); i++ {
+		if tokens[i].text == ")" && tokens[i].depth == 0 {
+			i++
+			break
+		}
+	}
+	for ; i < len(tokens); i++ {
+		if tokens[i].depth > 0 {
+			continue
+		}
+		if bodyStartWords[tokens[i].lower()] || (i+1 < len(tokens) && tokens[i+1].text == ":") {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitStatements splits the body of a compound statement on the
+// semicolons that aren't inside quotes or comments.
+func splitStatements(body string) []string {
+	var stmts []string
+	start := 0
+	for _, tok := range routineTokens(body) {
+		if tok.text == ";" {
+			stmts = append(stmts, body[start:tok.start])
+			start = tok.end
+		}
+	}
+	return append(stmts, body[start:])
+}
+
+// stripControlFlow removes the flow control that precedes a statement in a
+// compound statement, e.g. "IF x > 0 THEN UPDATE ..." becomes "UPDATE ...".
+// Statements that are nothing but flow control, e.g. "END IF", become "".
+func stripControlFlow(stmt string) string {
+	tokens := routineTokens(stmt)
+	i := 0
+	for i < len(tokens) {
+		switch tokens[i].lower() {
+		case "end", "until", "leave", "iterate":
+			return ""
+		case "begin", "else", "loop", "repeat":
+			i++
+			continue
+		case "if", "elseif", "case", "when", "while":
+			// skip the condition
+			j := i + 1
+			for ; j < len(tokens); j++ {
+				if tokens[j].depth == tokens[i].depth && (tokens[j].lower() == "then" || tokens[j].lower() == "do") {
+					break
+				}
+			}
+			i = j + 1
+			continue
+		}
+		if i+1 < len(tokens) && tokens[i+1].text == ":" {
+			// a label
+			i += 2
+			continue
+		}
+		break
+	}
+
+	if i >= len(tokens) {
+		return ""
+	}
+	return strings.TrimSpace(stmt[tokens[i].start:])
+}
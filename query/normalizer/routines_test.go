@@ -0,0 +1,134 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var routineTests = []struct {
+	ID                     string
+	Input                  string
+	ExpectedOutput         string
+	ExpectedStatement      string
+	ExpectedTables         []string
+	ExpectedBodyStatements []string
+}{
+	{"call",
+		"CALL proc(1, 'x')",
+		"call proc(?, ?)",
+		"call",
+		[]string{"proc"},
+		[]string{},
+	},
+	{"call with expressions",
+		"CALL `db`.`Proc`(@a, NOW(), CONCAT('a', 'b'))",
+		"call db.proc(@a, now(), concat(?, ?))",
+		"call",
+		[]string{"db.proc"},
+		[]string{},
+	},
+	{"call without arguments",
+		"CALL proc",
+		"call proc",
+		"call",
+		[]string{"proc"},
+		[]string{},
+	},
+	{"call without a procedure",
+		"CALL (1)",
+		"call (?)",
+		"",
+		nil,
+		nil,
+	},
+	{"create procedure",
+		`CREATE DEFINER=` + "`root`@`localhost`" + ` PROCEDURE p(IN a INT, OUT b VARCHAR(10))
+BEGIN
+  DECLARE done INT DEFAULT 0;
+  SELECT name INTO b FROM users WHERE id = a;
+  IF a > 10 THEN
+    UPDATE users SET seen = NOW() WHERE id = a;
+  ELSE
+    INSERT INTO log (msg) VALUES ('not; a statement');
+  END IF;
+  lbl: WHILE done < 5 DO
+    SET done = done + 1;
+  END WHILE lbl;
+END`,
+		"create definer=`root`@`localhost` procedure p(in a int, out b varchar(?)) begin ... end",
+		"create procedure",
+		[]string{"log", "users"},
+		[]string{
+			"declare done int default ?",
+			"select name into b from users where id = a",
+			"update users set seen = now() where id = a",
+			"insert into log(msg) values (?)",
+			"set done = done + ?",
+		},
+	},
+	{"create trigger",
+		"CREATE TRIGGER users_bi BEFORE INSERT ON users FOR EACH ROW SET NEW.created = NOW()",
+		"create trigger users_bi before insert on users for each row set new.created = now()",
+		"create trigger",
+		[]string{"users"},
+		[]string{"set new.created = now()"},
+	},
+	{"trigger rows aren't tables",
+		"CREATE TRIGGER orders_ai AFTER INSERT ON orders FOR EACH ROW BEGIN UPDATE stats SET total = total + NEW.amount WHERE id = OLD.stat_id; END",
+		"create trigger orders_ai after insert on orders for each row begin ... end",
+		"create trigger",
+		[]string{"orders", "stats"},
+		[]string{"update stats set total = total + new.amount where id = old.stat_id"},
+	},
+	{"create function",
+		"CREATE FUNCTION double_it(x INT) RETURNS INT DETERMINISTIC RETURN x * 2",
+		"create function double_it(x int) returns int deterministic return x * ?",
+		"create function",
+		nil,
+		[]string{"return x * ?"},
+	},
+	{"loadable function has no body",
+		"CREATE FUNCTION f RETURNS INTEGER SONAME 'f.so'",
+		"create function f returns integer soname ?",
+		"",
+		nil,
+		[]string{},
+	},
+}
+
+func TestParserRoutines(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range routineTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedStatement != n.LastStatement {
+			t.Error("test '" + test.ID + "' failed statement.  actual = " + n.LastStatement)
+		}
+		if fmt.Sprintf("%q", test.ExpectedTables) != fmt.Sprintf("%q", n.LastTables) {
+			t.Error("test '" + test.ID + "' failed tables.  actual = " + fmt.Sprintf("%q", n.LastTables))
+		}
+		if fmt.Sprintf("%q", test.ExpectedBodyStatements) != fmt.Sprintf("%q", n.LastBodyStatements) {
+			t.Error("test '" + test.ID + "' failed body statements.  actual = " + fmt.Sprintf("%q", n.LastBodyStatements))
+		}
+	}
+}
+
+func TestParserRoutineBodyIsOneQuery(t *testing.T) {
+	cache := normalizer.NewCache(10)
+	instrumentation := &recordingInstrumentation{}
+	n := &normalizer.Parser{Cache: cache, Instrumentation: instrumentation}
+
+	n.NormalizeQuery("CREATE PROCEDURE p() BEGIN SELECT a FROM t; UPDATE t SET a = 1; END")
+	if stats := cache.Stats(); stats.Len != 1 {
+		t.Errorf("body statements were cached.  actual = %+v", stats)
+	}
+	if actual := instrumentation.take(); strings.Contains(actual, "select") || strings.Contains(actual, "update") {
+		t.Error("body statements were instrumented.  actual = " + actual)
+	}
+}
@@ -1,15 +1,30 @@
 package normalizer
 
 import (
+	"time"
 	"unicode"
 )
 
 // Scanner represents state and options used for multiple calls to NormalizeQuery
 type Scanner struct {
+	// PreserveIdentifierCase lowercases only keywords and function names,
+	// leaving table and column names as written.
+	PreserveIdentifierCase bool
+	// Instrumentation, if set, receives the time spent in each call to
+	// NormalizeQuery, as PhaseScan.
+	Instrumentation Instrumentation
 }
 
 // NormalizeQuery converts an sql statement into a normalized version (downcased, with all string/numeric literals replaced with ?).  It most definitely does not validate that a query is syntactically correct.
 func (n *Scanner) NormalizeQuery(q string) string {
+	if n.Instrumentation != nil {
+		start := time.Now()
+		defer func() {
+			n.Instrumentation.Observe(PhaseScan, firstWord(q), time.Since(start))
+		}()
+	}
+
+	q = expandExecutableComments(q, false)
 
 	// three bools to manage our state, in order of priority.
 	var escaped bool
@@ -22,6 +37,30 @@ func (n *Scanner) NormalizeQuery(q string) string {
 
 	var rv []rune
 
+	// with PreserveIdentifierCase, where the word being scanned starts in
+	// rv (-1 between words), and whether we're inside a `quoted` identifier.
+	wordStart := -1
+	var backquoted, seenWord bool
+	var afterInto bool
+
+	// foldWord lowercases the word just scanned if it's a keyword, the
+	// statement's first word, or (when next is '(') a function name.
+	foldWord := func(next rune) {
+		if wordStart < 0 {
+			return
+		}
+		word := rv[wordStart:]
+		keyword := isKeywordRunes(word)
+		if keyword || !seenWord || (next == '(' && !afterInto) {
+			for i, r := range word {
+				word[i] = unicode.ToLower(r)
+			}
+		}
+		afterInto = keyword && (string(word) == "into" || string(word) == "table")
+		seenWord = true
+		wordStart = -1
+	}
+
 	maybeDeleteAsc := func() {
 		rvlen := len(rv)
 		if rvlen <= 4 {
@@ -70,6 +109,7 @@ func (n *Scanner) NormalizeQuery(q string) string {
 		// not escaped, quoted, or number.
 
 		if r == '"' || r == '\'' {
+			foldWord(r)
 			maybeAddSpace()
 			quoted = true
 			quoteRune = r
@@ -88,6 +128,7 @@ func (n *Scanner) NormalizeQuery(q string) string {
 		}
 
 		if r == ' ' {
+			foldWord(r)
 			needSpace = true
 			canStartNumber = true
 			continue
@@ -95,14 +136,25 @@ func (n *Scanner) NormalizeQuery(q string) string {
 			maybeAddSpace()
 
 			canStartNumber = r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			if !n.PreserveIdentifierCase {
+				r = unicode.ToLower(r)
+			} else if canStartNumber {
+				foldWord(r)
+				if r == '`' {
+					backquoted = !backquoted
+				}
+			} else if wordStart < 0 && !backquoted {
+				wordStart = len(rv)
+			}
 			if canStartNumber {
 				maybeDeleteAsc()
 			}
 
-			rv = append(rv, unicode.ToLower(r))
+			rv = append(rv, r)
 
 		}
 	}
+	foldWord(0)
 	maybeDeleteAsc()
 
 	return string(rv)
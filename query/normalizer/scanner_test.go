@@ -29,3 +29,22 @@ func TestScannerNormalization(t *testing.T) {
 	}
 
 }
+
+var scannerPreserveCaseTests = []struct{ ID, Input, Expected string }{
+	{"keywords folded", `SELECT UserName FROM Users WHERE Id = 5 ORDER BY UserName ASC`, "select UserName from Users where Id = ? order by UserName"},
+	{"functions folded", `SELECT COUNT(*), NOW() FROM Users`, "select count(*), now() from Users"},
+	{"insert column list", `INSERT INTO Users(Name) VALUES ('x')`, "insert into Users(Name) values (?)"},
+	{"backquoted keywords kept", "SELECT `Select` FROM `Order`", "select `Select` from `Order`"},
+	{"unknown statement", `BEGIN`, "begin"},
+}
+
+func TestScannerPreserveIdentifierCase(t *testing.T) {
+	n := &normalizer.Scanner{PreserveIdentifierCase: true}
+
+	for _, test := range scannerPreserveCaseTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.Expected != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package normalizer
+
+import (
+	"strings"
+)
+
+// Variable scopes.
+const (
+	ScopeSession     = "session"
+	ScopeGlobal      = "global"
+	ScopePersist     = "persist"
+	ScopePersistOnly = "persist_only"
+	ScopeUser        = "user"
+)
+
+// Variable is a variable assigned by a SET statement.
+type Variable struct {
+	// Name is the variable's lowercased name, without its @ or @@scope.
+	// prefix.
+	Name string
+	// Scope is one of ScopeSession, ScopeGlobal, ScopePersist,
+	// ScopePersistOnly or ScopeUser.
+	Scope string
+}
+
+// describeSet classifies a SET statement and records the variables it
+// assigns.  SET NAMES and SET TRANSACTION are classified as "set names" and
+// "set transaction", neither of which assigns variables as far as
+// LastVariables is concerned.
+func (n *Parser) describeSet(q string) {
+	tokens := routineTokens(q)
+	if len(tokens) < 2 {
+		return
+	}
+
+	n.LastStatement = "set"
+	n.LastVariables = make([]Variable, 0)
+
+	i := 1
+	if setScope(tokens[i].lower()) != "" && i+1 < len(tokens) {
+		i++
+	}
+	switch tokens[i].lower() {
+	case "names":
+		n.LastStatement = "set names"
+		return
+	case "transaction":
+		n.LastStatement = "set transaction"
+		return
+	case "password", "role", "default", "resource":
+		// not variable assignments
+		return
+	}
+
+	// a scope modifier applies to the assignments after it, up to the
+	// next modifier.
+	scope := ScopeSession
+	start := 1
+	for i := 1; i <= len(tokens); i++ {
+		if i == len(tokens) || (tokens[i].depth == 0 && (tokens[i].text == "," || tokens[i].text == ";")) {
+			assignment := tokens[start:i]
+			if len(assignment) > 0 {
+				if s := setScope(assignment[0].lower()); s != "" && (len(assignment) == 1 || assignment[1].text != "=") {
+					scope = s
+					assignment = assignment[1:]
+				}
+			}
+			if v, ok := setVariable(assignment, scope); ok {
+				n.LastVariables = append(n.LastVariables, v)
+			}
+			start = i + 1
+		}
+	}
+}
+
+// setVariable returns the variable assigned by the tokens of a single
+// assignment, e.g. "max_connections = 100" or "@@session.x = 1", given the
+// scope of the modifier before it.
+func setVariable(tokens []routineToken, scope string) (Variable, bool) {
+	if len(tokens) == 0 {
+		return Variable{}, false
+	}
+
+	name := tokens[0].lower()
+	switch {
+	case strings.HasPrefix(name, "@@"):
+		name = name[2:]
+		scope = ScopeSession
+		if dot := strings.IndexByte(name, '.'); dot >= 0 {
+			if s := setScope(name[:dot]); s != "" {
+				scope = s
+				name = name[dot+1:]
+			}
+		}
+	case strings.HasPrefix(name, "@"):
+		scope = ScopeUser
+		name = name[1:]
+		if name == "" && len(tokens) > 1 {
+			// @'quoted name'
+			name = tokens[1].lower()
+		}
+	}
+
+	name = strings.Trim(name, "`'\"")
+	if name == "" {
+		return Variable{}, false
+	}
+	return Variable{Name: name, Scope: scope}, true
+}
+
+// setScope returns the scope a SET modifier or @@ prefix names, or "".
+func setScope(modifier string) string {
+	switch modifier {
+	case "session", "local":
+		return ScopeSession
+	case "global":
+		return ScopeGlobal
+	case "persist":
+		return ScopePersist
+	case "persist_only":
+		return ScopePersistOnly
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var setTests = []struct {
+	ID                string
+	Input             string
+	ExpectedOutput    string
+	ExpectedStatement string
+	ExpectedVariables []normalizer.Variable
+}{
+	{"session variable",
+		"SET autocommit = 1",
+		"set autocommit = ?",
+		"set",
+		[]normalizer.Variable{{Name: "autocommit", Scope: normalizer.ScopeSession}},
+	},
+	{"scoped system variables",
+		"SET @@global.max_connections = 100, @@SESSION.sql_mode = '', @@wait_timeout = 10",
+		"set @@global.max_connections = ?, @@session.sql_mode = ?, @@wait_timeout = ?",
+		"set",
+		[]normalizer.Variable{
+			{Name: "max_connections", Scope: normalizer.ScopeGlobal},
+			{Name: "sql_mode", Scope: normalizer.ScopeSession},
+			{Name: "wait_timeout", Scope: normalizer.ScopeSession},
+		},
+	},
+	{"scope modifiers",
+		"SET GLOBAL max_connections = 100, PERSIST_ONLY back_log = 5, LOCAL sql_mode = ''",
+		"set global max_connections = ?, persist_only back_log = ?, local sql_mode = ?",
+		"set",
+		[]normalizer.Variable{
+			{Name: "max_connections", Scope: normalizer.ScopeGlobal},
+			{Name: "back_log", Scope: normalizer.ScopePersistOnly},
+			{Name: "sql_mode", Scope: normalizer.ScopeSession},
+		},
+	},
+	{"scope carried forward",
+		"SET GLOBAL max_connections = 100, back_log = 5, @@wait_timeout = 10, SESSION sql_mode = '', autocommit = 1",
+		"set global max_connections = ?, back_log = ?, @@wait_timeout = ?, session sql_mode = ?, autocommit = ?",
+		"set",
+		[]normalizer.Variable{
+			{Name: "max_connections", Scope: normalizer.ScopeGlobal},
+			{Name: "back_log", Scope: normalizer.ScopeGlobal},
+			{Name: "wait_timeout", Scope: normalizer.ScopeSession},
+			{Name: "sql_mode", Scope: normalizer.ScopeSession},
+			{Name: "autocommit", Scope: normalizer.ScopeSession},
+		},
+	},
+	{"scope without an assignment",
+		"SET GLOBAL",
+		"set global",
+		"set",
+		[]normalizer.Variable{},
+	},
+	{"persist",
+		"SET PERSIST innodb_buffer_pool_size = 1024",
+		"set persist innodb_buffer_pool_size = ?",
+		"set",
+		[]normalizer.Variable{{Name: "innodb_buffer_pool_size", Scope: normalizer.ScopePersist}},
+	},
+	{"user variables",
+		"SET @a = 1, @B := (SELECT 1, 2)",
+		"set @a = ?, @b := (select ?, ?)",
+		"set",
+		[]normalizer.Variable{{Name: "a", Scope: normalizer.ScopeUser}, {Name: "b", Scope: normalizer.ScopeUser}},
+	},
+	{"set names",
+		"SET NAMES utf8mb4 COLLATE utf8mb4_unicode_ci",
+		"set names utf8mb4 collate utf8mb4_unicode_ci",
+		"set names",
+		[]normalizer.Variable{},
+	},
+	{"set transaction",
+		"SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED",
+		"set session transaction isolation level read committed",
+		"set transaction",
+		[]normalizer.Variable{},
+	},
+}
+
+func TestParserSetVariables(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range setTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedStatement != n.LastStatement {
+			t.Error("test '" + test.ID + "' failed statement.  actual = " + n.LastStatement)
+		}
+		if fmt.Sprintf("%+v", test.ExpectedVariables) != fmt.Sprintf("%+v", n.LastVariables) {
+			t.Error("test '" + test.ID + "' failed variables.  actual = " + fmt.Sprintf("%+v", n.LastVariables))
+		}
+	}
+}
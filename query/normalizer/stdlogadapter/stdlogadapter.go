@@ -0,0 +1,38 @@
+// Package stdlogadapter lets a normalizer.Parser log to a standard library
+// log.Logger.
+package stdlogadapter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is a normalizer.Logger that logs to a log.Logger, with the keys
+// and values appended to the message as key=value pairs.  log.Logger has
+// no levels, so everything the Parser logs is written.
+type Logger struct {
+	logger *log.Logger
+}
+
+// New returns a Logger that logs to l, or to the standard logger if l is
+// nil.
+func New(l *log.Logger) *Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return &Logger{logger: l}
+}
+
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{}
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%q", keysAndValues[i], fmt.Sprint(value))
+	}
+	l.logger.Print(b.String())
+}
@@ -0,0 +1,26 @@
+package stdlogadapter_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+	"github.com/honeycombio/mysqltools/query/normalizer/stdlogadapter"
+)
+
+var _ normalizer.Logger = (*stdlogadapter.Logger)(nil)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New(&buf, "", 0)
+
+	n := &normalizer.Parser{Logger: stdlogadapter.New(l)}
+	n.NormalizeQuery("SELECT * FROM t WHERE")
+
+	actual := buf.String()
+	if !strings.HasPrefix(actual, "falling back to scan") || !strings.Contains(actual, `reason="parse_error"`) {
+		t.Error("unexpected log output.  actual = " + actual)
+	}
+}
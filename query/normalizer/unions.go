@@ -0,0 +1,80 @@
+package normalizer
+
+import (
+	"github.com/honeycombio/sqlparser"
+)
+
+// UnionRepeat is a special SQLNode used to render a run of identical UNION
+// branches collapsed to one, e.g. "select a from t union all ...".
+type UnionRepeat struct {
+	Branch sqlparser.SelectStatement
+	// Type is the UNION joining the branches, e.g. sqlparser.AST_UNION_ALL.
+	Type string
+	// Count is how many branches were collapsed.
+	Count int
+}
+
+func (u *UnionRepeat) Format(buf *sqlparser.TrackedBuffer) {
+	buf.Myprintf("%v%s...", u.Branch, u.Type)
+}
+
+func (u *UnionRepeat) Serialize(runes []rune) []rune {
+	runes = u.Branch.Serialize(runes)
+	runes = append(runes, []rune(u.Type)...)
+	return append(runes, []rune("...")...)
+}
+
+func (*UnionRepeat) ISelectStatement() {}
+func (*UnionRepeat) IStatement()       {}
+func (*UnionRepeat) IInsertRows()      {}
+
+// flattenUnion returns the branches of a chain of UNIONs in order, along
+// with the UNION type joining each branch to the one before it.
+func flattenUnion(node sqlparser.SelectStatement) ([]sqlparser.SelectStatement, []string) {
+	union, ok := node.(*sqlparser.Union)
+	if !ok {
+		return []sqlparser.SelectStatement{node}, nil
+	}
+	left, leftTypes := flattenUnion(union.Left)
+	right, rightTypes := flattenUnion(union.Right)
+	types := append(append(leftTypes, union.Type), rightTypes...)
+	return append(left, right...), types
+}
+
+// collapseUnionBranches collapses each run of identical branches joined by
+// the same type of UNION into a UnionRepeat.
+func collapseUnionBranches(branches []sqlparser.SelectStatement, types []string) ([]sqlparser.SelectStatement, []string) {
+	keys := make([]string, len(branches))
+	for i, branch := range branches {
+		keys[i] = string(sqlparser.Serialize(branch, 0))
+	}
+
+	var collapsed []sqlparser.SelectStatement
+	var collapsedTypes []string
+	for start := 0; start < len(branches); {
+		end := start + 1
+		for end < len(branches) && keys[end] == keys[start] && types[end-1] == types[start] {
+			end++
+		}
+
+		if start > 0 {
+			collapsedTypes = append(collapsedTypes, types[start-1])
+		}
+		if end-start > 1 {
+			collapsed = append(collapsed, &UnionRepeat{Branch: branches[start], Type: types[start], Count: end - start})
+		} else {
+			collapsed = append(collapsed, branches[start])
+		}
+		start = end
+	}
+	return collapsed, collapsedTypes
+}
+
+// buildUnion joins branches back into a chain of UNIONs.
+func buildUnion(branches []sqlparser.SelectStatement, types []string) sqlparser.SelectStatement {
+	node := branches[0]
+	for i, branch := range branches[1:] {
+		node = &sqlparser.Union{Type: types[i], Left: node, Right: branch}
+	}
+	return node
+}
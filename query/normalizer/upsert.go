@@ -0,0 +1,67 @@
+package normalizer
+
+import (
+	"github.com/honeycombio/sqlparser"
+)
+
+// rewriteInsertVariant rewrites the REPLACE and INSERT IGNORE statements
+// sqlparser can't parse as the plain INSERT it can, returning the rewritten
+// query and the statement's type, "replace" or "insert ignore".  Other
+// queries are returned as is, with an empty type.
+func rewriteInsertVariant(q string) (string, string) {
+	switch firstWord(q) {
+	case "replace":
+		tokens := routineTokens(q)
+		return q[:tokens[0].start] + "insert" + q[tokens[0].end:], "replace"
+	case "insert":
+		tokens := routineTokens(q)
+		q = quoteOnDupValues(q, tokens)
+		if len(tokens) > 1 && tokens[1].lower() == "ignore" {
+			return q[:tokens[1].start] + q[tokens[1].end:], "insert ignore"
+		}
+	}
+	return q, ""
+}
+
+// quoteOnDupValues quotes the name of any VALUES() function in an ON
+// DUPLICATE KEY UPDATE clause.  sqlparser loses the names of functions that
+// are keywords, so VALUES(a) would otherwise be named after some other
+// token.  Only the clause itself is changed, tokens before it keep their
+// offsets.
+func quoteOnDupValues(q string, tokens []routineToken) string {
+	for i := 3; i < len(tokens); i++ {
+		if tokens[i].lower() != "update" || tokens[i-1].lower() != "key" ||
+			tokens[i-2].lower() != "duplicate" || tokens[i-3].lower() != "on" {
+			continue
+		}
+
+		// work backwards so the offsets of earlier tokens stay valid.
+		for j := len(tokens) - 1; j > i; j-- {
+			tok := tokens[j]
+			if tok.lower() == "values" && tok.end < len(q) && q[tok.end] == '(' {
+				q = q[:tok.start] + "`" + tok.text + "`" + q[tok.end:]
+			}
+		}
+		return q
+	}
+	return q
+}
+
+// restoreInsertVariant undoes rewriteInsertVariant on a normalized INSERT.
+func restoreInsertVariant(normalized, variant string) string {
+	switch variant {
+	case "replace":
+		return "replace" + normalized[len("insert"):]
+	case "insert ignore":
+		return "insert ignore" + normalized[len("insert"):]
+	}
+	return normalized
+}
+
+// addOnDupColumns records the columns an ON DUPLICATE KEY UPDATE clause
+// assigns.
+func (n *Parser) addOnDupColumns(onDup sqlparser.OnDup) {
+	for _, ue := range onDup {
+		n.LastOnDupColumns = append(n.LastOnDupColumns, string(sqlparser.Serialize(ue.Name, 0)))
+	}
+}
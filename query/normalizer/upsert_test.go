@@ -0,0 +1,70 @@
+package normalizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+var upsertTests = []struct {
+	ID                   string
+	Input                string
+	ExpectedOutput       string
+	ExpectedStatement    string
+	ExpectedOnDupColumns []string
+}{
+	{"plain insert",
+		"INSERT INTO t (a, b) VALUES (1, 2)",
+		"insert into t(a,b) values (?, ?)",
+		"insert",
+		[]string{},
+	},
+	{"upsert",
+		"INSERT INTO t (a, b) VALUES (1, 2) ON DUPLICATE KEY UPDATE a = 3, b = VALUES(b) + 1",
+		"insert into t(a,b) values (?, ?) on duplicate key update a = ?, b = values(b) + ?",
+		"upsert",
+		[]string{"a", "b"},
+	},
+	{"replace",
+		"REPLACE INTO t (a, b) VALUES (1, 2)",
+		"replace into t(a,b) values (?, ?)",
+		"replace",
+		[]string{},
+	},
+	{"replace select",
+		"REPLACE INTO t SELECT * FROM u WHERE id = 5",
+		"replace into t select * from u where id = ?",
+		"replace",
+		[]string{},
+	},
+	{"insert ignore",
+		"INSERT IGNORE INTO t (a) VALUES (1)",
+		"insert ignore into t(a) values (?)",
+		"insert ignore",
+		[]string{},
+	},
+	{"insert ignore upsert",
+		"INSERT IGNORE INTO t (a) VALUES (1) ON DUPLICATE KEY UPDATE t.a = t.a + 1",
+		"insert ignore into t(a) values (?) on duplicate key update t.a = t.a + ?",
+		"upsert",
+		[]string{"t.a"},
+	},
+}
+
+func TestParserUpserts(t *testing.T) {
+	n := &normalizer.Parser{}
+
+	for _, test := range upsertTests {
+		actual := n.NormalizeQuery(test.Input)
+		if test.ExpectedOutput != actual {
+			t.Error("test '" + test.ID + "' failed normalization.  actual = " + actual)
+		}
+		if test.ExpectedStatement != n.LastStatement {
+			t.Error("test '" + test.ID + "' failed statement.  actual = " + n.LastStatement)
+		}
+		if fmt.Sprint(test.ExpectedOnDupColumns) != fmt.Sprint(n.LastOnDupColumns) {
+			t.Error("test '" + test.ID + "' failed on dup columns.  actual = " + fmt.Sprint(n.LastOnDupColumns))
+		}
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -48,14 +50,12 @@ func testQuery(input string) {
 	}
 }
 
-func main() {
-	file, err := os.Open(os.Args[1])
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
+var cacheSize = flag.Int("cache", 0, "the size of the ast normalizer's query cache, or 0 for no cache")
 
-	scanner := bufio.NewScanner(file)
+// readQueries calls query with each query in a mysql-test style file,
+// printing its --echo commands to out as they're reached.
+func readQueries(r io.Reader, out io.Writer, query func(string)) error {
+	scanner := bufio.NewScanner(r)
 	queryText := ""
 	for scanner.Scan() {
 		text := scanner.Text()
@@ -70,10 +70,10 @@ func main() {
 			command, args := c[0], c[1:]
 			switch command {
 			case "echo":
-				fmt.Println(strings.Join(args, " "))
+				fmt.Fprintln(out, strings.Join(args, " "))
 				continue
 			default:
-				fmt.Println("unhandled command: " + text)
+				fmt.Fprintln(out, "unhandled command: "+text)
 				continue
 			}
 		}
@@ -81,15 +81,33 @@ func main() {
 		queryText = queryText + " " + text
 		if strings.HasSuffix(queryText, ";") {
 			queryText = strings.TrimSuffix(queryText, ";")
-			testQuery(queryText)
+			query(queryText)
 			queryText = ""
 		}
 	}
+	return scanner.Err()
+}
 
-	if err := scanner.Err(); err != nil {
+func main() {
+	flag.Parse()
+	if *cacheSize > 0 {
+		astNormalizer.Cache = normalizer.NewCache(*cacheSize)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := readQueries(file, os.Stdout, testQuery); err != nil {
 		log.Fatal(err)
 	}
 
 	fmt.Printf("ast normalizer : %dms for %d queries (%d queries/minute). %d failures\n", astNormalizerTime.Nanoseconds()/1e6, astNormalizerSuccess, int64(float64(astNormalizerSuccess)/astNormalizerTime.Minutes()), astNormalizerFailure)
 	fmt.Printf("scan normalizer: %dms for %d queries (%d queries/minute). %d failures\n", scanNormalizerTime.Nanoseconds()/1e6, scanNormalizerSuccess, int64(float64(scanNormalizerSuccess)/scanNormalizerTime.Minutes()), scanNormalizerFailure)
+	if astNormalizer.Cache != nil {
+		stats := astNormalizer.Cache.Stats()
+		fmt.Printf("ast normalizer cache: %d hits, %d misses, %d evictions\n", stats.Hits, stats.Misses, stats.Evictions)
+	}
 }
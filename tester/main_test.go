@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/honeycombio/mysqltools/query/normalizer"
+)
+
+func loadCorpus(b *testing.B) []string {
+	file, err := os.Open("testdata/corpus.test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	var queries []string
+	err = readQueries(file, ioutil.Discard, func(q string) {
+		queries = append(queries, q)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return queries
+}
+
+func benchmarkParser(b *testing.B, n *normalizer.Parser) {
+	queries := loadCorpus(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, q := range queries {
+			n.NormalizeQuery(q)
+		}
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	benchmarkParser(b, &normalizer.Parser{})
+}
+
+func BenchmarkParserCached(b *testing.B) {
+	cache := normalizer.NewCache(1000)
+	benchmarkParser(b, &normalizer.Parser{Cache: cache})
+
+	stats := cache.Stats()
+	b.ReportMetric(float64(stats.Hits)/float64(stats.Hits+stats.Misses), "hit-ratio")
+}